@@ -0,0 +1,99 @@
+package cart
+
+import (
+	"context"
+	"net/http"
+)
+
+// WrapHandler adapts h to a Handler. The chain continues after h only when h
+// wrote nothing, otherwise the Context is aborted.
+func WrapHandler(h http.Handler) Handler {
+	return func(c *Context, next Next) {
+		h.ServeHTTP(c.Response, c.Request)
+		if c.Response.Written() {
+			c.Abort()
+			return
+		}
+		next()
+	}
+}
+
+// WrapHandlerFunc adapts f to a Handler, see WrapHandler.
+func WrapHandlerFunc(f http.HandlerFunc) Handler {
+	return WrapHandler(f)
+}
+
+type middlewareKey struct{}
+
+// middlewareCall is the state of one request passing through FromMiddleware.
+type middlewareCall struct {
+	c      *Context
+	next   Next
+	called bool
+}
+
+// FromMiddleware adapts a net/http middleware to a Handler. The middleware is
+// built once, the rest of the chain runs when it calls its next handler, with
+// the request and ResponseWriter it passes on. A middleware that answers the
+// request without calling next aborts the Context.
+func FromMiddleware(m func(http.Handler) http.Handler) Handler {
+	h := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := r.Context().Value(middlewareKey{}).(*middlewareCall)
+		if !ok {
+			// the request does not derive from the one passed to the
+			// middleware, find the call through the writer instead
+			call = writerCall(w)
+		}
+		if call == nil {
+			debugPrint("[WARNING] FromMiddleware: next called with an unknown request and ResponseWriter")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		call.called = true
+		c := call.c
+		c.Request = r
+		if rw, ok := w.(*ResponseWriter); ok && rw == c.Response {
+			call.next()
+			return
+		}
+
+		// the middleware wrapped the writer, write through it with a
+		// ResponseWriter of its own that flushes the header when done
+		outer := c.Response
+		inner := &ResponseWriter{}
+		inner.reset(w)
+		inner.discardBody = outer.discardBody
+		c.Response = inner
+		defer func() { c.Response = outer }()
+		call.next()
+		inner.WriteHeaderFinal()
+	}))
+
+	return func(c *Context, next Next) {
+		call := &middlewareCall{c: c, next: next}
+		r := c.Request.WithContext(context.WithValue(c.Request.Context(), middlewareKey{}, call))
+		rw := c.Response
+		outer := rw.call
+		rw.call = call
+		h.ServeHTTP(rw, r)
+		rw.call = outer
+		if !call.called {
+			c.Abort()
+		}
+	}
+}
+
+// writerCall returns the FromMiddleware call in progress on w, unwrapping
+// writers that wrap a ResponseWriter.
+func writerCall(w http.ResponseWriter) *middlewareCall {
+	for {
+		switch v := w.(type) {
+		case *ResponseWriter:
+			return v.call
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package cart
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type ctxKey string
+
+func TestWrapHandler(t *testing.T) {
+	app := New()
+	app.Route("/std").GET(func(c *Context) error {
+		c.String(200, "unreachable")
+		return nil
+	})
+	app.Use("/std", WrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		w.Write([]byte("from net/http"))
+	}))
+	app.Use("/pass", WrapHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen", "1")
+	}))
+	app.Route("/pass").GET(func(c *Context) error {
+		c.String(200, "cart")
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/std", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 201 || w.Body.String() != "from net/http" {
+		t.Errorf("expected wrapped handler to answer, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/pass", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Body.String() != "cart" || w.Header().Get("X-Seen") != "1" {
+		t.Errorf("expected chain to continue, got %q %q", w.Body.String(), w.Header().Get("X-Seen"))
+	}
+}
+
+func TestFromMiddleware(t *testing.T) {
+	built := 0
+	withValue := func(next http.Handler) http.Handler {
+		built++
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(context.WithValue(r.Context(), ctxKey("user"), "bob"))
+			w.Header().Set("X-Std", "1")
+			next.ServeHTTP(w, r)
+		})
+	}
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, "denied", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	app := New()
+	app.Use("/", FromMiddleware(withValue), FromMiddleware(deny))
+	app.Route("/me").GET(func(c *Context) error {
+		c.String(200, "%v", c.Request.Context().Value(ctxKey("user")))
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "token")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 200 || w.Body.String() != "bob" || w.Header().Get("X-Std") != "1" {
+			t.Errorf("expected replaced request, got %d %q", w.Code, w.Body.String())
+		}
+	}
+	if built != 1 {
+		t.Errorf("expected middleware to be built once, got %d", built)
+	}
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 401 || !strings.Contains(w.Body.String(), "denied") {
+		t.Errorf("expected middleware to abort, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (w upperWriter) Write(b []byte) (int, error) {
+	return w.ResponseWriter.Write([]byte(strings.ToUpper(string(b))))
+}
+
+func TestFromMiddlewareWrappedWriter(t *testing.T) {
+	upper := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(upperWriter{w}, r)
+		})
+	}
+
+	var status, size int
+	app := New()
+	app.Use("/", func(c *Context, next Next) {
+		next()
+		status, size = c.Response.Status(), c.Response.Size()
+	}, FromMiddleware(upper))
+	app.Route("/hello").GET(func(c *Context) error {
+		c.String(202, "hello")
+		return nil
+	})
+	app.Route("/empty").GET(func(c *Context) error {
+		c.Status(204)
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/hello", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 202 || w.Body.String() != "HELLO" {
+		t.Errorf("expected body through wrapped writer, got %d %q", w.Code, w.Body.String())
+	}
+	if status != 202 || size != 5 {
+		t.Errorf("expected outer writer to track 202/5, got %d/%d", status, size)
+	}
+
+	req = httptest.NewRequest("GET", "/empty", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 204 || status != 204 {
+		t.Errorf("expected status to reach the outer writer, got %d/%d", w.Code, status)
+	}
+}
+
+func TestFromMiddlewareNewContext(t *testing.T) {
+	detach := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(context.Background(), ctxKey("user"), "bob")))
+		})
+	}
+	replace := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, _ := http.NewRequest(r.Method, "/other", nil)
+			next.ServeHTTP(w, req)
+		})
+	}
+
+	app := New()
+	app.Use("/detach", FromMiddleware(detach))
+	app.Route("/detach").GET(func(c *Context) error {
+		c.String(200, "user=%v", c.Request.Context().Value(ctxKey("user")))
+		return nil
+	})
+	app.Use("/replace", FromMiddleware(replace))
+	app.Route("/replace").GET(func(c *Context) error {
+		c.String(200, c.Request.URL.Path)
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/detach", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 200 || w.Body.String() != "user=bob" {
+		t.Errorf("expected the detached request to reach the handler, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/replace", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 200 || w.Body.String() != "/other" {
+		t.Errorf("expected the new request to reach the handler, got %d %q", w.Code, w.Body.String())
+	}
+}
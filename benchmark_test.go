@@ -180,3 +180,41 @@ func benchHandlerParallel(b *testing.B, h http.Handler, path string) {
 		}
 	})
 }
+
+func benchTree() *Engine {
+	e := New()
+	for _, path := range []string{
+		"/",
+		"/api/v1/users",
+		"/api/v1/users/:id",
+		"/api/v1/users/:id/posts/:post",
+		"/api/v1/users/:id<int>/avatar",
+		"/static/*filepath",
+	} {
+		e.Route(path).GET(func(c *Context) error { return nil })
+	}
+	return e
+}
+
+// BenchmarkTreeMatch measures the matcher alone, params come from the pool.
+func BenchmarkTreeMatch(b *testing.B) {
+	e := benchTree()
+	tree := e.routing().table.tree
+	for _, bm := range []struct{ name, path string }{
+		{"Static", "/api/v1/users"},
+		{"Param", "/api/v1/users/me"},
+		{"Backtrack", "/api/v1/users/42/posts/7"},
+		{"CatchAll", "/static/css/site.css"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ps := e.getParams()
+				if handle, _ := tree.getValue(bm.path, ps); handle == nil {
+					b.Fatalf("no route for %s", bm.path)
+				}
+				e.putParams(ps)
+			}
+		})
+	}
+}
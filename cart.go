@@ -25,11 +25,14 @@ func New() *Engine {
 		Router:              Router{Path: "/"},
 		ForwardedByClientIP: true,
 		AppEngine:           false,
+		HandleOPTIONS:       true,
+		UnescapePathValues:  true,
 		delims:              render.Delims{Left: "{{", Right: "}}"},
 		FuncMap:             template.FuncMap{},
 	}
 
 	e.init()
+	e.FuncMap["url"] = e.URL
 	return e
 }
 
@@ -7,7 +7,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -449,3 +451,328 @@ func TestSetFuncMap(t *testing.T) {
 		t.Errorf("Expected HELLO, got %s", w.Body.String())
 	}
 }
+
+func TestRedirectFixedPath(t *testing.T) {
+	app := New()
+	app.CleanPath = true
+	app.RedirectFixedPath = true
+	app.Route("/users/:id").GET(func(c *Context) error {
+		id, _ := c.Param("id")
+		c.String(200, "user %s", id)
+		return nil
+	}).POST(func(c *Context) error {
+		return nil
+	})
+	app.Route("/docs/").GET(func(c *Context) error {
+		return nil
+	})
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"GET", "//Users/../USERS/1", 301, "/users/1"},
+		{"GET", "/USERS/1?x=y", 301, "/users/1?x=y"},
+		{"GET", "/users//1", 301, "/users/1"},
+		{"POST", "/Users/1", 308, "/users/1"},
+		{"GET", "/DOCS", 301, "/docs/"},
+		{"GET", "/users/1", 200, ""},
+		{"GET", "/nothing", 404, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s %s expected %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s %s expected Location %q, got %q", tt.method, tt.path, tt.location, loc)
+		}
+	}
+}
+
+func TestCleanPathOnly(t *testing.T) {
+	app := New()
+	app.CleanPath = true
+	app.Route("/users/:id").GET(func(c *Context) error {
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/a/../users/1", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 301 || w.Header().Get("Location") != "/users/1" {
+		t.Errorf("expected redirect to /users/1, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// case is not corrected without RedirectFixedPath
+	req = httptest.NewRequest("GET", "/USERS/1", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestServeFixedPath(t *testing.T) {
+	app := New()
+	app.CleanPath = true
+	app.RedirectFixedPath = true
+	app.ServeFixedPath = true
+	app.Route("/users/:id").GET(func(c *Context) error {
+		id, _ := c.Param("id")
+		c.String(200, "%s %s", c.Request.URL.Path, id)
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "//USERS/./Bob", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 200 || w.Body.String() != "/users/Bob Bob" {
+		t.Errorf("expected fixed path to be served, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTrailingSlashPolicy(t *testing.T) {
+	newApp := func(policy TrailingSlashPolicy) *Engine {
+		app := New()
+		app.TrailingSlash = policy
+		app.Route("/users/:id").GET(func(c *Context) error {
+			id, _ := c.Param("id")
+			c.String(200, "user %s", id)
+			return nil
+		})
+		app.Route("/docs/").GET(func(c *Context) error {
+			c.String(200, "docs")
+			return nil
+		})
+		return app
+	}
+
+	tests := []struct {
+		policy   TrailingSlashPolicy
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{TrailingSlashDefault, "/users/1/", 301, "", "/users/1"},
+		{TrailingSlashRedirect, "/docs", 301, "", "/docs/"},
+		{TrailingSlashStrict, "/users/1/", 404, "", ""},
+		{TrailingSlashStrict, "/docs", 404, "", ""},
+		{TrailingSlashServe, "/users/1/", 200, "user 1", ""},
+		{TrailingSlashServe, "/docs", 200, "docs", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		newApp(tt.policy).ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("policy %d %s: expected %d, got %d", tt.policy, tt.path, tt.code, w.Code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("policy %d %s: expected body %q, got %q", tt.policy, tt.path, tt.body, w.Body.String())
+		}
+		if loc := w.Header().Get("Location"); loc != tt.location {
+			t.Errorf("policy %d %s: expected Location %q, got %q", tt.policy, tt.path, tt.location, loc)
+		}
+	}
+}
+
+func TestTrailingSlashPolicyRouter(t *testing.T) {
+	app := New()
+	app.Route("/strict").GET(func(c *Context) error {
+		c.String(200, "strict")
+		return nil
+	}).SetTrailingSlash(TrailingSlashStrict)
+	app.Route("/loose").GET(func(c *Context) error {
+		c.String(200, "loose")
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/strict/", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("expected 404 for strict router, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/loose/", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 301 || w.Header().Get("Location") != "/loose" {
+		t.Errorf("expected redirect to /loose, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestTrailingSlashPolicyMiddleware(t *testing.T) {
+	app := New()
+	app.TrailingSlash = TrailingSlashStrict
+	app.Use("/admin/", func(c *Context, next Next) {
+		c.Response.Header().Set("X-Admin", "1")
+		next()
+	})
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 || w.Header().Get("X-Admin") != "" {
+		t.Errorf("expected strict 404 without admin middleware, got %d %q", w.Code, w.Header().Get("X-Admin"))
+	}
+
+	req = httptest.NewRequest("GET", "/admin/x", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 || w.Header().Get("X-Admin") != "1" {
+		t.Errorf("expected admin middleware below /admin/, got %d %q", w.Code, w.Header().Get("X-Admin"))
+	}
+}
+
+func TestUseRawPath(t *testing.T) {
+	app := New()
+	app.UseRawPath = true
+	app.Route("/files/:name").GET(func(c *Context) error {
+		name, _ := c.Param("name")
+		c.String(200, "file %s", name)
+		return nil
+	})
+	app.Route("/static/*path").GET(func(c *Context) error {
+		p, _ := c.Param("path")
+		c.String(200, "static %s", p)
+		return nil
+	})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/files/a%2Fb", "file a/b"},
+		{"/files/a%20b", "file a b"},
+		{"/static/a%2Fb/c", "static /a/b/c"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 200 || w.Body.String() != tt.body {
+			t.Errorf("%s: expected %q, got %d %q", tt.path, tt.body, w.Code, w.Body.String())
+		}
+	}
+
+	app.UnescapePathValues = false
+	req := httptest.NewRequest("GET", "/files/a%2Fb", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Body.String() != "file a%2Fb" {
+		t.Errorf("expected escaped value, got %q", w.Body.String())
+	}
+
+	app.UnescapePathValues = true
+	app.RawCatchAll = true
+	req = httptest.NewRequest("GET", "/static/a%2Fb/c", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Body.String() != "static /a%2Fb/c" {
+		t.Errorf("expected escaped catch-all, got %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/files/a%2Fb/", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 301 || w.Header().Get("Location") != "/files/a%2Fb" {
+		t.Errorf("expected redirect keeping the encoding, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestDecodedPathSplitsEncodedSlash(t *testing.T) {
+	app := New()
+	app.Route("/files/:name").GET(func(c *Context) error {
+		c.String(200, "file")
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/files/a%2Fb", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("expected 404 without UseRawPath, got %d", w.Code)
+	}
+}
+
+func TestRegisterWhileServing(t *testing.T) {
+	app := New()
+	app.Route("/ping").GET(func(c *Context) error {
+		c.String(200, "pong")
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				app.ServeHTTP(w, httptest.NewRequest("GET", "/ping", nil))
+				if w.Code != 200 {
+					t.Errorf("expected 200 while registering, got %d", w.Code)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		path := "/live/" + strconv.Itoa(i)
+		app.Route(path).GET(func(c *Context) error {
+			c.String(200, "live")
+			return nil
+		})
+		app.Use(path, func(c *Context, next Next) { next() })
+		app.Route("/ping").UseFor([]string{"POST"}, func(c *Context, next Next) { next() })
+	}
+	close(stop)
+	wg.Wait()
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/live/49", nil))
+	if w.Code != 200 || w.Body.String() != "live" {
+		t.Errorf("expected route added while serving, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestEngineFreeze(t *testing.T) {
+	app := New()
+	app.Route("/ping").GET(func(c *Context) error {
+		c.String(200, "pong")
+		return nil
+	})
+	app.Freeze()
+
+	for name, register := range map[string]func(){
+		"route":  func() { app.Route("/late").GET(func(c *Context) error { return nil }) },
+		"use":    func() { app.Use("/", func(c *Context, next Next) { next() }) },
+		"host":   func() { app.Host("api.example.com") },
+		"name":   func() { app.Route("/ping").Name("ping") },
+		"group":  func() { app.Group(func(g *Router) { g.Use("/", func(c *Context, next Next) { next() }) }) },
+		"method": func() { app.Route("/ping").POST(func(c *Context) error { return nil }) },
+	} {
+		if recv := catchPanic(register); recv == nil {
+			t.Errorf("%s: expected panic after Freeze", name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/ping", nil))
+	if w.Code != 200 {
+		t.Errorf("expected frozen engine to serve, got %d", w.Code)
+	}
+}
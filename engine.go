@@ -4,10 +4,14 @@ import (
 	"context"
 	"html/template"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -18,12 +22,17 @@ type Engine struct {
 	Router
 	mu         sync.RWMutex
 	delims     render.Delims
-	routers    map[string]*Router
+	table      *routeTable
+	hosts      []*hostRoute
+	names      map[string]*Router
 	pool       sync.Pool
 	paramsPool sync.Pool
-	tree       *node
+	snapshot   atomic.Pointer[routing]
+	frozen     bool
+	pre        []HandlerCompose
 
-	NotFound HandlerFinal
+	NotFound         HandlerFinal
+	MethodNotAllowed HandlerFinal
 
 	FuncMap  template.FuncMap
 	Template *template.Template
@@ -32,6 +41,29 @@ type Engine struct {
 	AppEngine           bool
 	TrustedProxies      []string
 
+	// HandleOPTIONS answers OPTIONS requests automatically with an Allow header
+	// built from the registered methods, unless the route has its own OPTIONS handler.
+	HandleOPTIONS bool
+
+	// CleanPath removes repeated slashes and . or .. elements from a path that
+	// matched no route, RedirectFixedPath also corrects its case and trailing slash.
+	// The request is redirected to the corrected route with 301 (GET, HEAD) or
+	// 308, or served directly when ServeFixedPath is set.
+	CleanPath         bool
+	RedirectFixedPath bool
+	ServeFixedPath    bool
+
+	// TrailingSlash decides how a path that only matches a route with or without
+	// a trailing slash is answered, Router.SetTrailingSlash overrides it per path.
+	TrailingSlash TrailingSlashPolicy
+
+	// UseRawPath matches routes on the escaped path, so an encoded slash stays
+	// inside its parameter. UnescapePathValues then unescapes each parameter,
+	// except the catch-all parameter when RawCatchAll is set.
+	UseRawPath         bool
+	UnescapePathValues bool
+	RawCatchAll        bool
+
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
@@ -45,6 +77,130 @@ type Engine struct {
 
 var _ http.Handler = &Engine{}
 
+// TrailingSlashPolicy is the answer to a request whose path matches a route
+// only after adding or removing a trailing slash.
+type TrailingSlashPolicy uint8
+
+const (
+	// TrailingSlashDefault uses the policy of the Engine, which redirects by default.
+	TrailingSlashDefault TrailingSlashPolicy = iota
+	// TrailingSlashRedirect redirects with 301 (GET) or 307 to the matched route.
+	TrailingSlashRedirect
+	// TrailingSlashStrict treats the path as not found.
+	TrailingSlashStrict
+	// TrailingSlashServe serves the matched route without redirecting.
+	TrailingSlashServe
+)
+
+// routing is a copy of the default routes and the hosts that requests are
+// served from without locking. Route changes drop it and the next request
+// builds a new one, requests in flight keep the copy they started with.
+type routing struct {
+	table *routeTable
+	hosts []*hostRoute
+	pre   HandlerCompose // runs before the route is matched
+}
+
+// routeTable holds the routers of the default host or of one Engine.Host pattern.
+type routeTable struct {
+	tree    *node
+	routers map[string]*Router
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{tree: &node{}, routers: make(map[string]*Router)}
+}
+
+// snapshot returns a copy of t that later registrations do not change, with
+// its tree compressed for matching.
+func (t *routeTable) snapshot() *routeTable {
+	routers := make(map[string]*Router, len(t.routers))
+	for path, r := range t.routers {
+		cp := *r
+		cp.methods = slices.Clone(r.methods)
+		routers[path] = &cp
+	}
+	tree := t.tree.clone(func(handle interface{}) interface{} {
+		return routers[handle.(*Router).Path]
+	})
+	tree.compress()
+	return &routeTable{tree: tree, routers: routers}
+}
+
+// RouteInfo describes a registered path. Middleware lists the names of the
+// middleware chain shared by every method in execution order, len(Middleware)
+// is its length. MethodMiddleware lists the full chain of each registered
+// method, including the middleware added with UseFor and in a Group. On a
+// MiddlewareOnly path it lists the chains of the methods given to UseFor.
+type RouteInfo struct {
+	Host             string
+	Path             string
+	Name             string
+	Methods          []string
+	Middleware       []string
+	MethodMiddleware map[string][]string
+	MiddlewareOnly   bool
+}
+
+// Routes returns the registered paths sorted by host and path,
+// routes of the default host have an empty Host.
+func (e *Engine) Routes() []RouteInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	routes := make([]RouteInfo, 0, len(e.table.routers))
+	routes = e.table.appendRoutes(routes, "")
+	for _, h := range e.hosts {
+		routes = h.table.appendRoutes(routes, h.pattern)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+func (t *routeTable) appendRoutes(routes []RouteInfo, host string) []RouteInfo {
+	for _, router := range t.routers {
+		methods := make([]string, 0, len(router.methods))
+		var methodMiddleware map[string][]string
+		for i, entry := range router.methods {
+			if !slices.Contains(methods, entry.key) {
+				methods = append(methods, entry.key)
+				if methodMiddleware == nil {
+					methodMiddleware = make(map[string][]string, len(router.methods))
+				}
+				methodMiddleware[entry.key] = router.chainNames(&router.methods[i])
+			}
+		}
+		if len(router.methods) == 0 {
+			// a middleware-only path lists the chains of its UseFor methods
+			for _, u := range router.composedFor {
+				for _, key := range u.methods {
+					if _, ok := methodMiddleware[key]; !ok {
+						if methodMiddleware == nil {
+							methodMiddleware = make(map[string][]string)
+						}
+						methodMiddleware[key] = router.chainNames(&method{key: key})
+					}
+				}
+			}
+		}
+		sort.Strings(methods)
+		routes = append(routes, RouteInfo{
+			Host:             host,
+			Path:             router.Path,
+			Name:             router.name,
+			Methods:          methods,
+			Middleware:       append([]string(nil), router.middleware...),
+			MethodMiddleware: methodMiddleware,
+			MiddlewareOnly:   len(router.methods) == 0 && (router.composed != nil || len(router.composedFor) > 0),
+		})
+	}
+	return routes
+}
+
 func (e *Engine) allocateContext() *Context {
 	return &Context{Response: &ResponseWriter{}}
 }
@@ -75,16 +231,22 @@ func (e *Engine) recycleContext(c *Context) {
 func (e *Engine) findRouter(absolutePath string) (*Router, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	router := e.routers[absolutePath]
+	return e.findTableRouter(e.table, absolutePath)
+}
+
+// findTableRouter looks absolutePath up in t without locking. Requests pass
+// their immutable routing snapshot, findRouter the live table under e.mu.
+func (e *Engine) findTableRouter(t *routeTable, absolutePath string) (*Router, bool) {
+	router := t.routers[absolutePath]
 	if router == nil {
 		return nil, false
 	}
 	return router, true
 }
 
-func (e *Engine) getRouter(absolutePath string) (*Router, bool) {
+func (e *Engine) getRouter(t *routeTable, absolutePath string) (*Router, bool) {
 	e.mu.RLock()
-	router := e.routers[absolutePath]
+	router := t.routers[absolutePath]
 	e.mu.RUnlock()
 	find := true
 	if router == nil {
@@ -93,27 +255,120 @@ func (e *Engine) getRouter(absolutePath string) (*Router, bool) {
 			Engine:  e,
 			Path:    absolutePath,
 			methods: make([]method, 0),
+			table:   t,
 		}
 	}
 	return router, find
 }
 
+// addRoute must be called with e.mu held.
 func (e *Engine) addRoute(router *Router) {
 	if router.Path[0] != '/' {
 		panic("Path must begin with '/' in path '" + router.Path + "'")
 	}
+	t := router.table
+	//add router
+	debugPrint("Add Router %s", router.Path)
+	t.tree.addRoute(router.Path, router)
+	router.recompose(t.parent(router.Path)) // Pre-calculate middleware chains
+	t.routers[router.Path] = router
+}
+
+// modify must be called with e.mu held before the routes change. It panics
+// once the Engine is frozen and drops the routing snapshot.
+func (e *Engine) modify() {
+	if e.frozen {
+		panic("routes cannot be changed after Engine.Freeze")
+	}
+	e.snapshot.Store(nil)
+}
+
+// routing returns the current routing snapshot, building it if the routes
+// changed since the last request.
+func (e *Engine) routing() *routing {
+	if rt := e.snapshot.Load(); rt != nil {
+		return rt
+	}
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	if e.tree == nil {
-		e.tree = &node{}
+	if rt := e.snapshot.Load(); rt != nil {
+		return rt
+	}
+	rt := &routing{table: e.table.snapshot(), hosts: make([]*hostRoute, len(e.hosts)), pre: compose(e.pre...)}
+	for i, h := range e.hosts {
+		cp := *h
+		cp.table = h.table.snapshot()
+		rt.hosts[i] = &cp
+	}
+	e.snapshot.Store(rt)
+	return rt
+}
+
+// Freeze rejects any later route change with a panic, so the routes served
+// after startup are known. Requests are served from a snapshot either way.
+func (e *Engine) Freeze() {
+	e.routing()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.frozen = true
+}
+
+// parent returns the nearest router whose middleware applies to path: the
+// same path with a trailing slash, then each enclosing prefix with and
+// without a trailing slash, up to "/".
+func (t *routeTable) parent(path string) *Router {
+	if path == "/" {
+		return nil
+	}
+	if path[len(path)-1] != '/' {
+		if r := t.routers[path+"/"]; r != nil {
+			return r
+		}
+	}
+	p := strings.TrimSuffix(path, "/")
+	for p != "/" {
+		if i := strings.LastIndexByte(p, '/'); i <= 0 {
+			p = "/"
+		} else {
+			p = p[:i]
+		}
+		if r := t.routers[p]; r != nil {
+			return r
+		}
+		if p != "/" {
+			if r := t.routers[p+"/"]; r != nil {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// recomposeBelow rebuilds the middleware chains of the router at path and
+// of every router below it, parents before their children. It must be called
+// with e.mu held.
+func (e *Engine) recomposeBelow(t *routeTable, path string) {
+	routers := make([]*Router, 0)
+	for p, r := range t.routers {
+		if underPath(p, path) {
+			routers = append(routers, r)
+		}
+	}
+	// fewer segments first, "/a/" is the parent of "/a"
+	sort.Slice(routers, func(i, j int) bool {
+		pi, pj := routers[i].Path, routers[j].Path
+		si, sj := strings.Count(strings.TrimSuffix(pi, "/"), "/"), strings.Count(strings.TrimSuffix(pj, "/"), "/")
+		if si != sj {
+			return si < sj
+		}
+		if li, lj := lastChar(pi) == '/', lastChar(pj) == '/'; li != lj {
+			return li
+		}
+		return pi < pj
+	})
+	for _, r := range routers {
+		r.recompose(t.parent(r.Path))
 	}
-	//add router
-	debugPrint("Add Router %s", router.Path)
-	router.flatten() // Pre-calculate middleware chains
-	e.routers[router.Path] = router
-	e.tree.addRoute(router.Path, router)
-	//if _, found := e.tree.findCaseInsensitivePath(router.Path, true); !found {
-	//}
 }
 
 func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -124,9 +379,9 @@ func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	e.serveHTTP(c)
 }
 
-func (e *Engine) serve404(c *Context, path string) {
+func (e *Engine) serve404(c *Context, t *routeTable, path string) {
 	// 404 error
-	c.Router, _ = e.findRouter(path)
+	c.Router, _ = e.findTableRouter(t, path)
 	if c.Response.Size() == -1 && c.Response.Status() == 200 {
 		if e.NotFound != nil {
 			if err := e.NotFound(c); err != nil && e.ErrorHandler != nil {
@@ -140,6 +395,34 @@ func (e *Engine) serve404(c *Context, path string) {
 	}
 }
 
+// serve405 answers a request whose path matched a router that has no handler
+// for the request method. The Allow header lists the registered methods.
+func (e *Engine) serve405(c *Context, router *Router) {
+	c.Router = router
+	if c.Response.Size() == -1 && c.Response.Status() == 200 {
+		c.Header("Allow", router.allowed())
+		if e.MethodNotAllowed != nil {
+			c.Status(http.StatusMethodNotAllowed)
+			if err := e.MethodNotAllowed(c); err != nil && e.ErrorHandler != nil {
+				e.ErrorHandler(c, err)
+			}
+		} else {
+			c.ErrorHTML(405,
+				"405 Method Not Allowed",
+				"The method <b style='color:red'>"+template.HTMLEscapeString(c.Request.Method)+"</b> is not allowed for <b>"+template.HTMLEscapeString(router.Path)+"</b>")
+		}
+	}
+}
+
+// serveOptions answers an OPTIONS request for a router without an OPTIONS handler.
+func (e *Engine) serveOptions(c *Context, router *Router) {
+	c.Router = router
+	if c.Response.Size() == -1 && c.Response.Status() == 200 {
+		c.Header("Allow", router.allowed())
+		c.Status(http.StatusNoContent)
+	}
+}
+
 func (e *Engine) serveHTTP(c *Context) {
 	if e.OnRequest != nil {
 		e.OnRequest(c)
@@ -147,29 +430,121 @@ func (e *Engine) serveHTTP(c *Context) {
 	if e.OnResponse != nil {
 		defer e.OnResponse(c)
 	}
+	if pre := e.routing().pre; pre != nil {
+		pre(c, func() { e.route(c) })()
+		c.Response.WriteHeaderFinal()
+		return
+	}
+	e.route(c)
+}
+
+// route serves c with the route matching its request, after the pre-routing
+// handlers had their chance to change the request.
+func (e *Engine) route(c *Context) {
+	if c.Request.Method == "HEAD" {
+		c.Response.discardBody = true
+	}
+	e.handleHTTP(c)
+}
+
+// Pre adds handlers that run before the route is matched, in the order they
+// are added. A handler may change c.Request, for example its URL.Path or
+// Method, to change the route that serves it, or answer and abort without
+// calling next.
+func (e *Engine) Pre(handlers ...Handler) {
+	if len(handlers) == 0 {
+		return
+	}
+	e.addPre(makeCompose(handlers...))
+}
+
+// addPre adds handler to the chain run before the route is matched.
+func (e *Engine) addPre(handler HandlerCompose) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.modify()
+	e.pre = append(e.pre, handler)
+}
+
+// handleHTTP looks up the router for the request path and runs its handlers.
+func (e *Engine) handleHTTP(c *Context) {
 	path := c.Request.URL.Path
+	if e.UseRawPath {
+		path = c.Request.URL.EscapedPath()
+	}
 	httpMethod := c.Request.Method
 
 	var (
 		router *Router
-		ps     *Params
 		tsr    bool
 	)
-	e.mu.RLock()
-	root := e.tree
-	if root != nil {
-		r, p, t := root.getValue(path, e.getParams)
-		if r != nil {
-			router = r.(*Router)
-			ps = p
-		}
+	rt := e.routing()
+	table := rt.table
+	// host params come first, path params are appended to the same Params
+	ps := e.getParams()
+	if len(rt.hosts) > 0 {
+		table = e.matchHost(rt, c.Request.Host, ps)
+	}
+	hostParams := len(*ps)
+	if r, t := table.tree.getValue(path, ps); r != nil {
+		router = r.(*Router)
+	} else {
 		tsr = t
 	}
-	e.mu.RUnlock()
+	strict := false
+	if tsr {
+		alt := toggleTrailingSlash(path)
+		var altRouter *Router
+		if ar, _ := table.tree.getValue(alt, nil); ar != nil {
+			altRouter = ar.(*Router)
+		}
+		switch e.trailingSlashPolicy(altRouter) {
+		case TrailingSlashStrict:
+			tsr, strict = false, true
+		case TrailingSlashServe:
+			if altRouter != nil {
+				table.tree.getValue(alt, ps)
+				router, tsr = altRouter, false
+			}
+		}
+	}
+	fixed, fix := "", false
+	if router == nil && !tsr && !strict && (e.CleanPath || e.RedirectFixedPath) {
+		fixed, fix = e.fixPath(table, path)
+		fix = fix && fixed != path
+	}
+	if len(*ps) == 0 {
+		e.putParams(ps)
+		ps = nil
+	}
+	c.Params = ps
+	if e.UseRawPath && e.UnescapePathValues && ps != nil {
+		e.unescapeParams((*ps)[hostParams:])
+	}
+
+	if fix {
+		e.setPath(c.Request.URL, fixed)
+		if e.ServeFixedPath {
+			debugPrint("Serve fixed path %s -> %s", path, fixed)
+			if c.Params != nil {
+				e.putParams(c.Params)
+				c.Params = nil
+			}
+			e.handleHTTP(c)
+			return
+		}
+		code := http.StatusMovedPermanently
+		if httpMethod != "GET" && httpMethod != "HEAD" {
+			code = http.StatusPermanentRedirect
+		}
+		debugPrint("Redirect fixed path %s -> %s (%d)", path, fixed, code)
+		http.Redirect(c.Response, c.Request, c.Request.URL.String(), code)
+		c.Response.WriteHeaderFinal()
+		return
+	}
 
 	if router != nil {
 		c.Router = router
-		c.Params = ps
 
 		// Get pre-composed handler
 		var handler HandlerCompose
@@ -182,12 +557,19 @@ func (e *Engine) serveHTTP(c *Context) {
 		if handler != nil {
 			handler(c, noopNext)()
 		} else {
-			// try middleware only
+			// try middleware only, then answer OPTIONS or 405 if the path has handlers for other methods
+			final := func() { e.serve404(c, table, path) }
+			if len(router.methods) > 0 {
+				if httpMethod == "OPTIONS" && e.HandleOPTIONS {
+					final = func() { e.serveOptions(c, router) }
+				} else {
+					final = func() { e.serve405(c, router) }
+				}
+			}
 			if router.composed != nil {
-				final404 := func() { e.serve404(c, path) }
-				router.composed(c, final404)()
+				router.composed(c, final)()
 			} else {
-				e.serve404(c, path)
+				final()
 			}
 		}
 		c.Response.WriteHeaderFinal()
@@ -197,36 +579,79 @@ func (e *Engine) serveHTTP(c *Context) {
 		if httpMethod != "GET" {
 			code = 307
 		}
-		if len(path) > 1 && path[len(path)-1] == '/' {
-			c.Request.URL.Path = path[:len(path)-1]
-		} else {
-			c.Request.URL.Path = path + "/"
-		}
+		e.setPath(c.Request.URL, toggleTrailingSlash(path))
 		http.Redirect(c.Response, c.Request, c.Request.URL.String(), code)
+		c.Response.WriteHeaderFinal()
 		return
 	}
 	//find / middleware
-	r, composed := e.mixComposed(path)
+	mr, composed := e.mixComposed(table, path)
 	if composed != nil {
-		c.Router = r
-		final404 := func() { e.serve404(c, path) }
+		c.Router = mr
+		final404 := func() { e.serve404(c, table, path) }
 		composed(c, final404)()
 	} else {
-		e.serve404(c, path)
+		e.serve404(c, table, path)
 	}
 	c.Response.WriteHeaderFinal()
 }
 
-func (e *Engine) mixComposed(absolutePath string) (*Router, HandlerCompose) {
+// unescapeParams unescapes path parameters matched on the escaped path,
+// a value that is not a valid escape sequence is kept as is.
+func (e *Engine) unescapeParams(ps Params) {
+	for i := range ps {
+		// only a catch-all value starts with a slash
+		if e.RawCatchAll && strings.HasPrefix(ps[i].Value, "/") {
+			continue
+		}
+		if v, err := url.PathUnescape(ps[i].Value); err == nil {
+			ps[i].Value = v
+		}
+	}
+}
+
+// setPath replaces the path of u with p, which is escaped when UseRawPath is set.
+func (e *Engine) setPath(u *url.URL, p string) {
+	if e.UseRawPath {
+		if v, err := url.PathUnescape(p); err == nil {
+			u.Path, u.RawPath = v, p
+			return
+		}
+	}
+	u.Path, u.RawPath = p, ""
+}
+
+// fixPath returns the canonical path of a route for a path that matched nothing,
+// after cleaning it with CleanPath and looking it up case-insensitively with
+// RedirectFixedPath. It takes no lock, t is the routing snapshot of the request.
+func (e *Engine) fixPath(t *routeTable, p string) (string, bool) {
+	fixed := p
+	if e.CleanPath {
+		fixed = cleanPath(p)
+	}
+	if e.RedirectFixedPath {
+		return t.tree.findCaseInsensitivePath(fixed, e.TrailingSlash != TrailingSlashStrict)
+	}
+	if fixed != p {
+		if handle, _ := t.tree.getValue(fixed, nil); handle != nil {
+			return fixed, true
+		}
+	}
+	return "", false
+}
+
+func (e *Engine) mixComposed(t *routeTable, absolutePath string) (*Router, HandlerCompose) {
 	path := absolutePath
 	for {
-		if pr, find := e.findRouter(path); find {
+		if pr, find := e.findTableRouter(t, path); find {
 			return pr, pr.composed
 		}
 
-		// Check with trailing slash if not already present
+		// Check with trailing slash if not already present,
+		// a strict router only covers the path itself with the slash
 		if path != "/" && path[len(path)-1] != '/' {
-			if pr, find := e.findRouter(path + "/"); find {
+			if pr, find := e.findTableRouter(t, path+"/"); find &&
+				(path != absolutePath || e.trailingSlashPolicy(pr) != TrailingSlashStrict) {
 				return pr, pr.composed
 			}
 		}
@@ -246,6 +671,17 @@ func (e *Engine) mixComposed(absolutePath string) (*Router, HandlerCompose) {
 	return nil, nil
 }
 
+// trailingSlashPolicy returns the policy of r, falling back to the Engine's.
+func (e *Engine) trailingSlashPolicy(r *Router) TrailingSlashPolicy {
+	if r != nil && r.trailingSlash != TrailingSlashDefault {
+		return r.trailingSlash
+	}
+	if e.TrailingSlash != TrailingSlashDefault {
+		return e.TrailingSlash
+	}
+	return TrailingSlashRedirect
+}
+
 func (e *Engine) init() {
 	e.Router = Router{
 		Path: "/",
@@ -254,8 +690,9 @@ func (e *Engine) init() {
 	e.pool.New = func() interface{} {
 		return e.allocateContext()
 	}
-	e.tree = &node{}
-	e.routers = make(map[string]*Router)
+	e.table = newRouteTable()
+	e.Router.table = e.table
+	e.names = make(map[string]*Router)
 
 	e.ReadTimeout = 90 * time.Second
 	e.WriteTimeout = 90 * time.Second
@@ -357,5 +794,9 @@ func (engine *Engine) SetHTMLTemplate(templ *template.Template) {
 }
 
 func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
-	engine.FuncMap = funcMap
+	engine.FuncMap = make(template.FuncMap, len(funcMap)+1)
+	engine.FuncMap["url"] = engine.URL
+	for name, fn := range funcMap {
+		engine.FuncMap[name] = fn
+	}
 }
@@ -0,0 +1,113 @@
+package cart
+
+import "slices"
+
+// group is the middleware scope of Router.Group. Its middleware applies only
+// to the handlers declared through the group and its nested groups.
+type group struct {
+	parent  *group
+	uses    []groupUse
+	routers []*Router // routers with handlers declared in the group
+}
+
+type groupUse struct {
+	path    string
+	methods []string // nil for every method
+	handler HandlerCompose
+	names   []string
+}
+
+// Group calls fn with a router for the same path whose Use applies only to
+// the routes declared through it. Group middleware runs after the middleware
+// of the route's path, outer groups first.
+func (r *Router) Group(fn func(g *Router)) *Router {
+	g := r.groupRouter(r.Path, &group{parent: r.group})
+	if fn != nil {
+		fn(g)
+	}
+	return g
+}
+
+func (r *Router) groupRouter(path string, g *group) *Router {
+	return &Router{
+		Engine:        r.Engine,
+		Path:          path,
+		table:         r.table,
+		trailingSlash: r.trailingSlash,
+		group:         g,
+	}
+}
+
+// chain returns the middleware of g and its parents that applies to path
+// and httpMethod.
+func (g *group) chain(path, httpMethod string) HandlerCompose {
+	if g == nil {
+		return nil
+	}
+	var handlers []HandlerCompose
+	if parent := g.parent.chain(path, httpMethod); parent != nil {
+		handlers = append(handlers, parent)
+	}
+	for _, u := range g.uses {
+		if underPath(path, u.path) && (u.methods == nil || slices.Contains(u.methods, httpMethod)) {
+			handlers = append(handlers, u.handler)
+		}
+	}
+	return compose(handlers...)
+}
+
+// chainNames returns the names of the middleware in chain(path, httpMethod).
+func (g *group) chainNames(path, httpMethod string) []string {
+	if g == nil {
+		return nil
+	}
+	names := g.parent.chainNames(path, httpMethod)
+	for _, u := range g.uses {
+		if underPath(path, u.path) && (u.methods == nil || slices.Contains(u.methods, httpMethod)) {
+			names = append(names, u.names...)
+		}
+	}
+	return names
+}
+
+// use adds handler for the routes of g at or below path, including those
+// declared before it.
+func (g *group) use(e *Engine, path string, methods []string, handler HandlerCompose, names []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.modify()
+	g.uses = append(g.uses, groupUse{path: path, methods: methods, handler: handler, names: names})
+	for _, r := range g.routers {
+		r.flatten()
+	}
+}
+
+// add records that r has handlers declared in g. It must be called with
+// e.mu held.
+func (g *group) add(r *Router) {
+	for ; g != nil; g = g.parent {
+		if !slices.Contains(g.routers, r) {
+			g.routers = append(g.routers, r)
+		}
+	}
+}
+
+// remove forgets r in g and its parents, except in the groups that declared
+// one of the remaining methods of r. It must be called with e.mu held.
+func (g *group) remove(r *Router, methods []method) {
+	for ; g != nil; g = g.parent {
+		if !slices.ContainsFunc(methods, func(m method) bool { return m.group.within(g) }) {
+			g.routers = slices.DeleteFunc(g.routers, func(x *Router) bool { return x == r })
+		}
+	}
+}
+
+// within reports whether g is outer or nested in it.
+func (g *group) within(outer *group) bool {
+	for ; g != nil; g = g.parent {
+		if g == outer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package cart
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouterGroup(t *testing.T) {
+	var trace []string
+	mark := func(name string) Handler {
+		return func(c *Context, next Next) {
+			trace = append(trace, name)
+			next()
+		}
+	}
+	final := func(c *Context) error {
+		trace = append(trace, "handler")
+		return nil
+	}
+
+	e := New()
+	e.Use("/", mark("root"))
+	e.Group(func(g *Router) {
+		g.Route("/login").POST(final)
+		g.Use("/", mark("limit"))
+		g.Route("/signup").POST(final).GET(final)
+		g.Group(func(admin *Router) {
+			admin.Use("/admin", mark("admin"))
+			admin.Route("/admin/users").GET(final)
+		})
+	})
+	e.Route("/about").GET(final)
+	e.Route("/login").GET(final)
+
+	tests := []struct {
+		method, path string
+		trace        string
+	}{
+		{"POST", "/login", "root limit handler"},
+		{"GET", "/login", "root handler"},
+		{"POST", "/signup", "root limit handler"},
+		{"GET", "/signup", "root limit handler"},
+		{"HEAD", "/signup", "root limit handler"},
+		{"GET", "/admin/users", "root limit admin handler"},
+		{"GET", "/about", "root handler"},
+	}
+	for _, tt := range tests {
+		trace = nil
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+		if got := strings.Join(trace, " "); got != tt.trace {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.trace, got)
+		}
+	}
+}
+
+func TestRouterGroupPrefix(t *testing.T) {
+	var trace []string
+	e := New()
+	api := e.Route("/api")
+	api.Group(func(g *Router) {
+		g.Use("/private", func(c *Context, next Next) {
+			trace = append(trace, "auth")
+			next()
+		})
+		g.Route("/private/data").GET(func(c *Context) error {
+			trace = append(trace, "private")
+			return nil
+		})
+		g.Route("/public").GET(func(c *Context) error {
+			trace = append(trace, "public")
+			return nil
+		})
+	})
+	e.Route("/api/private/other").GET(func(c *Context) error {
+		trace = append(trace, "other")
+		return nil
+	})
+
+	for path, want := range map[string]string{
+		"/api/private/data":  "auth private",
+		"/api/public":        "public",
+		"/api/private/other": "other",
+	} {
+		trace = nil
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if got := strings.Join(trace, " "); got != want {
+			t.Errorf("%s: expected %q, got %q", path, want, got)
+		}
+	}
+}
+
+func TestRouterGroupName(t *testing.T) {
+	limited := false
+	e := New()
+	final := func(c *Context) error { return nil }
+	e.Group(func(g *Router) {
+		g.Use("/", func(c *Context, next Next) {
+			limited = true
+			next()
+		})
+		g.Route("/login").GET(final).Name("login").POST(final)
+		g.Route("/users/:id").Name("user").GET(final)
+	})
+
+	names := make(map[string]string)
+	for _, route := range e.Routes() {
+		names[route.Path] = route.Name
+	}
+	if names["/login"] != "login" || names["/users/:id"] != "user" {
+		t.Errorf("expected group route names in Routes, got %v", names)
+	}
+	if u, err := e.URL("user", 7); err != nil || u != "/users/7" {
+		t.Errorf("expected /users/7, got %q %v", u, err)
+	}
+
+	// Name keeps returning the group view
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/login", nil))
+	if !limited {
+		t.Error("expected POST after Name to stay in the group")
+	}
+}
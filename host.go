@@ -0,0 +1,107 @@
+package cart
+
+import (
+	"strings"
+)
+
+// hostRoute is a host pattern registered with Engine.Host and its own routers.
+type hostRoute struct {
+	pattern string
+	labels  []string
+	params  int
+	table   *routeTable
+	root    *Router
+}
+
+// Host returns the root router for requests whose Host matches pattern.
+// Labels of the pattern are matched case-insensitively and a label written
+// as :name binds that label to a parameter, e.g. ":tenant.example.com".
+// The port of the request is ignored. Routes and middleware registered on the
+// returned router form their own tree; requests for unmatched hosts are served
+// by the default routes.
+func (e *Engine) Host(pattern string) *Router {
+	pattern = strings.ToLower(pattern)
+	labels := strings.Split(pattern, ".")
+	params := 0
+	for _, label := range labels {
+		if label == "" {
+			panic("host pattern '" + pattern + "' has an empty label")
+		}
+		if label[0] == ':' {
+			if !validParamName(label[1:]) {
+				panic("wildcards must be named with a non-empty simple name in host '" + pattern + "'")
+			}
+			params++
+		} else if strings.ContainsAny(label, ":*") {
+			panic("wildcards must occupy a full label in host '" + pattern + "'")
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, h := range e.hosts {
+		if h.pattern == pattern {
+			return h.root
+		}
+	}
+	e.modify()
+	h := &hostRoute{pattern: pattern, labels: labels, params: params, table: newRouteTable()}
+	h.root = &Router{Engine: e, Path: "/", table: h.table}
+
+	// static hosts are matched first, then hosts with fewer parameters
+	pos := len(e.hosts)
+	for i, existing := range e.hosts {
+		if existing.params > params {
+			pos = i
+			break
+		}
+	}
+	e.hosts = append(e.hosts, nil)
+	copy(e.hosts[pos+1:], e.hosts[pos:])
+	e.hosts[pos] = h
+	debugPrint("Add Host %s", pattern)
+	return h.root
+}
+
+// matchHost returns the routers of rt for host, appending its parameters to ps.
+func (e *Engine) matchHost(rt *routing, host string, ps *Params) *routeTable {
+	if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	host = strings.ToLower(host)
+	for _, h := range rt.hosts {
+		if h.params == 0 {
+			if host == h.pattern {
+				return h.table
+			}
+			continue
+		}
+		mark := len(*ps)
+		if h.match(host, ps) {
+			return h.table
+		}
+		*ps = (*ps)[:mark]
+	}
+	return rt.table
+}
+
+func (h *hostRoute) match(host string, ps *Params) bool {
+	for i, label := range h.labels {
+		part := host
+		if i < len(h.labels)-1 {
+			var found bool
+			if part, host, found = strings.Cut(host, "."); !found {
+				return false
+			}
+		}
+		if label[0] == ':' {
+			if part == "" {
+				return false
+			}
+			*ps = append(*ps, Param{Key: label[1:], Value: part})
+		} else if part != label {
+			return false
+		}
+	}
+	return true
+}
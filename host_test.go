@@ -0,0 +1,91 @@
+package cart
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEngineHost(t *testing.T) {
+	app := New()
+	app.Route("/").GET(func(c *Context) error {
+		c.String(200, "default")
+		return nil
+	})
+
+	api := app.Host("api.example.com")
+	api.Use("/", func(c *Context, next Next) {
+		c.Header("X-Api", "1")
+		next()
+	})
+	api.Route("/").GET(func(c *Context) error {
+		c.String(200, "api")
+		return nil
+	})
+
+	tenant := app.Host(":tenant.example.com")
+	tenant.Route("/users/:id").GET(func(c *Context) error {
+		name, _ := c.Param("tenant")
+		id, _ := c.Param("id")
+		c.String(200, "%s/%s", name, id)
+		return nil
+	})
+	tenant.Route("/").GET(func(c *Context) error {
+		name, _ := c.Param("tenant")
+		c.String(200, "tenant %s", name)
+		return nil
+	})
+
+	if app.Host("API.example.com") != api {
+		t.Error("expected Host to return the existing router for the same pattern")
+	}
+
+	tests := []struct {
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"api.example.com", "/", 200, "api"},
+		{"API.Example.com:8080", "/", 200, "api"},
+		{"acme.example.com", "/", 200, "tenant acme"},
+		{"acme.example.com", "/users/7", 200, "acme/7"},
+		{"acme.example.com", "/missing", 404, ""},
+		{"a.b.example.com", "/", 200, "default"},
+		{"example.com", "/", 200, "default"},
+		{"other.org", "/", 200, "default"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s%s expected %d, got %d", tt.host, tt.path, tt.code, w.Code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s%s expected body %q, got %q", tt.host, tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "api.example.com"
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Header().Get("X-Api") != "1" {
+		t.Error("expected host middleware to run")
+	}
+
+	routes := app.Routes()
+	if len(routes) != 4 || routes[0].Host != "" || routes[1].Host != ":tenant.example.com" {
+		t.Errorf("unexpected routes %+v", routes)
+	}
+}
+
+func TestEngineHostInvalid(t *testing.T) {
+	app := New()
+	for _, pattern := range []string{"", "a..com", ":.example.com", "x:y.example.com", "*.example.com"} {
+		if recv := catchPanic(func() { app.Host(pattern) }); recv == nil {
+			t.Errorf("expected panic for host pattern %q", pattern)
+		}
+	}
+}
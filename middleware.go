@@ -4,9 +4,14 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"hash/fnv"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // RequestID returns a middleware that adds a unique ID to each request
@@ -107,3 +112,197 @@ func CORS(config ...CORSConfig) Handler {
 		next()
 	}
 }
+
+// DefaultOverrideMethods are the methods MethodOverride allows by default.
+var DefaultOverrideMethods = []string{"PUT", "PATCH", "DELETE"}
+
+// MethodOverride lets a POST request choose the method it is routed with,
+// through the X-HTTP-Method-Override header or the _method field of a URL
+// encoded form, so HTML forms can reach PUT, PATCH and DELETE routes. Only
+// the given methods, or DefaultOverrideMethods without any, are accepted.
+// It runs before the route is matched and never parses multipart bodies.
+func (e *Engine) MethodOverride(methods ...string) {
+	if len(methods) == 0 {
+		methods = DefaultOverrideMethods
+	}
+	allowed := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		if !validMethod(m) {
+			panic("invalid http method '" + m + "' for method override")
+		}
+		allowed[strings.ToUpper(m)] = true
+	}
+	e.Pre(func(c *Context, next Next) {
+		if c.Request.Method == "POST" {
+			m := c.Request.Header.Get("X-HTTP-Method-Override")
+			if m == "" && isURLEncodedForm(c.Request) {
+				// multipart bodies are left alone, so handlers can still stream them
+				if err := c.Request.ParseForm(); err == nil {
+					m = c.Request.PostForm.Get("_method")
+				}
+			}
+			if m = strings.ToUpper(m); allowed[m] {
+				c.Request.Method = m
+			}
+		}
+		next()
+	})
+}
+
+func isURLEncodedForm(r *http.Request) bool {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return ct == "application/x-www-form-urlencoded"
+}
+
+// RateLimitConfig defines the config for RateLimit middleware
+type RateLimitConfig struct {
+	// Limit is the number of requests a key may make per Period. Burst is how
+	// many of them it may make at once, Limit when zero.
+	Limit  int
+	Period time.Duration
+	Burst  int
+
+	// KeyFunc returns the key a request is counted for, Context.ClientIP by default.
+	KeyFunc func(*Context) string
+
+	// Store keeps the token buckets, a new MemoryStore by default.
+	Store LimiterStore
+}
+
+// LimitResult is the state of a token bucket after a request took a token from it.
+type LimitResult struct {
+	Allowed    bool
+	Remaining  int           // tokens left in the bucket
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until a token is available, when not allowed
+}
+
+// LimiterStore keeps the token buckets of RateLimit. Take takes a token from
+// the bucket of key, which holds up to burst tokens and gains one every
+// interval, creating a full bucket for a new key.
+type LimiterStore interface {
+	Take(key string, burst int, interval time.Duration) (LimitResult, error)
+}
+
+// RateLimit returns a middleware that limits the requests of each key with a
+// token bucket. Every response carries the RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers, a limited request is answered with 429 Too Many
+// Requests and Retry-After. Requests pass when the store fails.
+func RateLimit(config RateLimitConfig) Handler {
+	cfg := config
+	if cfg.Limit <= 0 || cfg.Period <= 0 {
+		panic("rate limit needs a positive Limit and Period")
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = cfg.Limit
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = (*Context).ClientIP
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	interval := cfg.Period / time.Duration(cfg.Limit)
+	limit := strconv.Itoa(cfg.Burst)
+
+	return func(c *Context, next Next) {
+		res, err := cfg.Store.Take(cfg.KeyFunc(c), cfg.Burst, interval)
+		if err != nil {
+			debugPrint("RateLimit store error: %v", err)
+			next()
+			return
+		}
+		c.Header("RateLimit-Limit", limit)
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+const memoryStoreShards = 64
+
+// MemoryStore is an in-memory LimiterStore. Keys are spread over shards with
+// their own lock. A bucket that is full again is dropped the next time its
+// shard is used, since a new bucket starts full anyway.
+type MemoryStore struct {
+	shards [memoryStoreShards]memoryShard
+	now    func() time.Time
+}
+
+type memoryShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	nextSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // when tokens was computed
+	full   time.Time // when the bucket is full again
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{now: time.Now}
+	for i := range s.shards {
+		s.shards[i].buckets = make(map[string]*tokenBucket)
+	}
+	return s
+}
+
+// Take implements LimiterStore.
+func (s *MemoryStore) Take(key string, burst int, interval time.Duration) (LimitResult, error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%memoryStoreShards]
+	now := s.now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if now.After(shard.nextSweep) {
+		shard.sweep(now)
+		shard.nextSweep = now.Add(time.Duration(burst) * interval)
+	}
+
+	b := shard.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		shard.buckets[key] = b
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += float64(elapsed) / float64(interval)
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+		b.last = now
+	}
+
+	var res LimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(interval))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((float64(burst) - b.tokens) * float64(interval))
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// sweep drops the buckets that are full again.
+func (s *memoryShard) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package cart
 
 import (
+	"bytes"
 	"compress/gzip"
 	"io"
+	"mime/multipart"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCORS(t *testing.T) {
@@ -43,6 +47,99 @@ func TestCORS(t *testing.T) {
 	}
 }
 
+func TestMethodOverride(t *testing.T) {
+	app := New()
+	app.MethodOverride()
+	app.Route("/items/:id", func(r *Router) {
+		r.POST(func(c *Context) error {
+			c.String(200, "post")
+			return nil
+		})
+		r.DELETE(func(c *Context) error {
+			c.String(200, "delete")
+			return nil
+		})
+		r.PUT(func(c *Context) error {
+			c.String(200, "put")
+			return nil
+		})
+	})
+
+	tests := []struct {
+		method, header, form, want string
+	}{
+		{"POST", "", "_method=delete", "delete"},
+		{"POST", "PUT", "", "put"},
+		{"POST", "PUT", "_method=DELETE", "put"},
+		{"POST", "CONNECT", "", "post"},
+		{"POST", "", "", "post"},
+		{"GET", "DELETE", "", "405"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/items/1", strings.NewReader(tt.form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tt.header != "" {
+			req.Header.Set("X-HTTP-Method-Override", tt.header)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		got := w.Body.String()
+		if w.Code != 200 {
+			got = strconv.Itoa(w.Code)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q %q: got %q, want %q", tt.method, tt.header, tt.form, got, tt.want)
+		}
+	}
+}
+
+func TestMethodOverrideMultipart(t *testing.T) {
+	app := New()
+	app.MethodOverride()
+	upload := func(c *Context) error {
+		mr, err := c.Request.MultipartReader()
+		if err != nil {
+			c.String(500, err.Error())
+			return nil
+		}
+		part, err := mr.NextPart()
+		if err != nil {
+			c.String(500, err.Error())
+			return nil
+		}
+		data, _ := io.ReadAll(part)
+		c.String(200, c.Request.Method+" "+string(data))
+		return nil
+	}
+	app.Route("/upload", func(r *Router) {
+		r.POST(upload)
+		r.PUT(upload)
+	})
+
+	for _, tt := range []struct{ header, want string }{
+		{"", "POST file"},   // _method of a multipart form is ignored
+		{"PUT", "PUT file"}, // the header still applies
+	} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		fw.Write([]byte("file"))
+		mw.WriteField("_method", "PUT")
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		if tt.header != "" {
+			req.Header.Set("X-HTTP-Method-Override", tt.header)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 200 || w.Body.String() != tt.want {
+			t.Errorf("header %q: got %d %q, want %q", tt.header, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
+
 func TestGzip(t *testing.T) {
 	app := New()
 	app.Use("/", Gzip())
@@ -114,3 +211,88 @@ func TestClientIP(t *testing.T) {
 		t.Errorf("Expected ClientIP 192.168.1.50, got %s", w.Body.String())
 	}
 }
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	app := New()
+	app.Use("/", RateLimit(RateLimitConfig{Limit: 2, Period: time.Second, Store: store}))
+	app.Route("/").GET(func(c *Context) error {
+		c.String(200, "ok")
+		return nil
+	})
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		w := request("192.0.2.1:1000")
+		if w.Code != 200 || w.Header().Get("RateLimit-Remaining") != remaining || w.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("request %d: got %d remaining %q", i, w.Code, w.Header().Get("RateLimit-Remaining"))
+		}
+	}
+	w := request("192.0.2.1:1001")
+	if w.Code != 429 || w.Header().Get("Retry-After") != "1" || w.Header().Get("RateLimit-Reset") != "1" {
+		t.Errorf("expected 429 with Retry-After 1, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w.Body.String() == "ok" {
+		t.Error("limited request reached the handler")
+	}
+	if w := request("192.0.2.2:1000"); w.Code != 200 {
+		t.Errorf("expected another client to pass, got %d", w.Code)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if w := request("192.0.2.1:1000"); w.Code != 200 {
+		t.Errorf("expected a refilled token, got %d", w.Code)
+	}
+}
+
+func TestRateLimitKeyFunc(t *testing.T) {
+	app := New()
+	app.Use("/", RateLimit(RateLimitConfig{
+		Limit:   1,
+		Period:  time.Minute,
+		KeyFunc: func(c *Context) string { return c.Request.Header.Get("X-API-Key") },
+	}))
+	app.Route("/").GET(func(c *Context) error { return nil })
+
+	for _, tt := range []struct {
+		key  string
+		code int
+	}{{"a", 200}, {"b", 200}, {"a", 429}} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", tt.key)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("key %s: expected %d, got %d", tt.key, tt.code, w.Code)
+		}
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 1000; i++ {
+		store.Take(strconv.Itoa(i), 5, time.Second)
+	}
+	now = now.Add(10 * time.Second)
+	store.Take("new", 5, time.Second)
+
+	// the shard of the new key dropped the buckets that are full again
+	for i := range store.shards {
+		shard := &store.shards[i]
+		if _, ok := shard.buckets["new"]; ok && len(shard.buckets) != 1 {
+			t.Errorf("expected the full buckets to expire, %d left", len(shard.buckets)-1)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cart
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type mountPrefixKey struct{}
+
+// MountPrefix returns the path prefix stripped from r by Router.Mount,
+// prefixes of nested mounts are joined.
+func MountPrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(mountPrefixKey{}).(string)
+	return prefix
+}
+
+// Mount serves relativePath and every path below it with handler. The prefix
+// is stripped from the request path and available through MountPrefix, the
+// middleware of the prefix runs first. A mounted *Engine keeps its own
+// NotFound and ErrorHandler.
+func (r *Router) Mount(relativePath string, handler http.Handler) *Router {
+	absolutePath := joinPaths(r.Path, relativePath)
+	segments := 0
+	if absolutePath != "/" {
+		absolutePath = strings.TrimSuffix(absolutePath, "/")
+		for _, segment := range strings.Split(absolutePath[1:], "/") {
+			if strings.HasPrefix(segment, "*") || (strings.Contains(segment, ":") && strings.HasSuffix(segment, "?")) {
+				panic("catch-all and optional wildcards are not allowed in mount path '" + absolutePath + "'")
+			}
+			segments++
+		}
+	}
+
+	mount := func(c *Context, next Next) {
+		req := c.Request
+		u := *req.URL
+		var prefix string
+		if r.Engine.UseRawPath {
+			var rest string
+			prefix, rest = cutSegments(req.URL.EscapedPath(), segments)
+			r.Engine.setPath(&u, rest)
+			if p, err := url.PathUnescape(prefix); err == nil {
+				prefix = p
+			}
+		} else {
+			prefix, u.Path = cutSegments(req.URL.Path, segments)
+			if u.RawPath != "" {
+				_, u.RawPath = cutSegments(u.RawPath, segments)
+			}
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		if u.RawPath != "" && u.RawPath[0] != '/' {
+			u.RawPath = "/" + u.RawPath
+		}
+
+		ctx := context.WithValue(req.Context(), mountPrefixKey{}, MountPrefix(req)+prefix)
+		sub := req.WithContext(ctx)
+		sub.URL = &u
+		handler.ServeHTTP(c.Response, sub)
+	}
+	r.Route(joinPaths(relativePath, "/*mount")).ANY(mount)
+	return r.Route(relativePath).ANY(mount)
+}
+
+// cutSegments splits p after its first n segments.
+func cutSegments(p string, n int) (prefix, rest string) {
+	i := 0
+	for ; n > 0; n-- {
+		if i+1 >= len(p) {
+			return p, ""
+		}
+		j := strings.IndexByte(p[i+1:], '/')
+		if j < 0 {
+			return p, ""
+		}
+		i += j + 1
+	}
+	return p[:i], p[i:]
+}
@@ -0,0 +1,115 @@
+package cart
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMountHandler(t *testing.T) {
+	app := New()
+	app.Use("/static", func(c *Context, next Next) {
+		c.Header("X-Parent", "1")
+		next()
+	})
+	app.Mount("/static", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(202)
+		w.Write([]byte(MountPrefix(r) + " " + r.URL.Path))
+	}))
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/static", "/static /"},
+		{"/static/", "/static /"},
+		{"/static/css/app.css", "/static /css/app.css"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 202 || w.Body.String() != tt.body {
+			t.Errorf("%s: expected 202 %q, got %d %q", tt.path, tt.body, w.Code, w.Body.String())
+		}
+		if w.Header().Get("X-Parent") != "1" {
+			t.Errorf("%s: expected parent middleware to run", tt.path)
+		}
+	}
+}
+
+func TestMountParamPrefix(t *testing.T) {
+	app := New()
+	app.Mount("/tenants/:id/files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(MountPrefix(r) + " " + r.URL.Path))
+	}))
+
+	req := httptest.NewRequest("DELETE", "/tenants/7/files/a.txt", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Body.String() != "/tenants/7/files /a.txt" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestMountEngine(t *testing.T) {
+	sub := New()
+	sub.NotFound = func(c *Context) error {
+		c.String(404, "sub not found")
+		return nil
+	}
+	sub.ErrorHandler = func(c *Context, err error) {
+		c.String(500, "sub error: %v", err)
+	}
+	sub.Route("/users/:id").GET(func(c *Context) error {
+		id, _ := c.Param("id")
+		c.String(200, "%s user %s", MountPrefix(c.Request), id)
+		return nil
+	})
+	sub.Route("/fail").GET(func(c *Context) error {
+		return errors.New("boom")
+	})
+	sub.Mount("/nested", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(MountPrefix(r) + " " + r.URL.Path))
+	}))
+
+	app := New()
+	app.NotFound = func(c *Context) error {
+		c.String(404, "app not found")
+		return nil
+	}
+	app.Mount("/api", sub)
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/users/1", 200, "/api user 1"},
+		{"/api/missing", 404, "sub not found"},
+		{"/api/fail", 500, "sub error: boom"},
+		{"/api/nested/x", 200, "/api/nested /x"},
+		{"/missing", 404, "app not found"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.path, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMountInvalidPath(t *testing.T) {
+	app := New()
+	for _, path := range []string{"/files/*path", "/list/:page?"} {
+		recv := catchPanic(func() {
+			app.Mount(path, http.NotFoundHandler())
+		})
+		if recv == nil {
+			t.Errorf("expected panic for mount path %s", path)
+		}
+	}
+}
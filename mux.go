@@ -0,0 +1,149 @@
+package cart
+
+import (
+	"net/http"
+	"strings"
+)
+
+// muxRestParam names the catch-all behind a ServeMux pattern ending in a slash.
+const muxRestParam = "_"
+
+type muxWildcard struct {
+	name string
+	rest bool
+}
+
+// HandleFunc registers handler for a net/http ServeMux pattern such as
+// "GET /items/{id}", "/files/{path...}" or "example.com/{$}". Wildcard values
+// are available through both Context.Param and Request.PathValue, a pattern
+// without a method matches every method and one ending in a slash matches
+// every path below it. As with ServeMux, patterns may name the wildcard at
+// the same position differently; middleware of the path sees the name of the
+// pattern registered first there.
+func (e *Engine) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) *Router {
+	httpMethod, host, path, wildcards := parseMuxPattern(pattern)
+	r := &e.Router
+	if host != "" {
+		r = e.Host(host)
+	}
+	// the tree may know the wildcards under other names, they are matched
+	// by position: the path params are the last ones, a trailing slash adds
+	// an unnamed catch-all after the wildcards
+	params := len(wildcards)
+	if strings.HasSuffix(path, "*"+muxRestParam) || strings.HasSuffix(path, "*"+muxRestParam+"?") {
+		params++
+	}
+	route := r.Route(reuseWildcardNames(r, path))
+
+	h := func(c *Context, next Next) {
+		if c.Params != nil && len(wildcards) > 0 && len(*c.Params) >= params {
+			ps := (*c.Params)[len(*c.Params)-params:]
+			for i, w := range wildcards {
+				ps[i].Key = w.name
+				if w.rest {
+					// ServeMux values of {name...} have no leading slash
+					ps[i].Value = strings.TrimPrefix(ps[i].Value, "/")
+				}
+				c.Request.SetPathValue(w.name, ps[i].Value)
+			}
+		}
+		handler(c.Response, c.Request)
+	}
+	return route.handle(httpMethod, route.Path, makeCompose(h))
+}
+
+// reuseWildcardNames renames the wildcards of path to the params the tree of
+// r already has at the same position, so "/items/{id}" and
+// "/items/{name}/edit" share a node as they do in ServeMux.
+func reuseWildcardNames(r *Router, path string) string {
+	r.Engine.mu.RLock()
+	defer r.Engine.mu.RUnlock()
+	segments := splitRoutePath(path)
+	cur := r.table.tree
+	for i, segment := range segments {
+		var next *node
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			next = cur.plainParamChild()
+		case strings.HasPrefix(segment, "*"):
+			next = cur.catchAllChild()
+		default:
+			next = cur.staticChild(segment)
+		}
+		if next == nil {
+			break
+		}
+		if next.nType != static {
+			segments[i] = segment[:1] + next.path
+			if strings.HasSuffix(segment, "?") {
+				segments[i] += "?"
+			}
+		}
+		cur = next
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// parseMuxPattern translates a ServeMux pattern "[METHOD ][HOST]/[PATH]"
+// into a route path of the tree.
+func parseMuxPattern(pattern string) (httpMethod, host, path string, wildcards []muxWildcard) {
+	httpMethod = "ANY"
+	rest := pattern
+	if i := strings.IndexAny(rest, " \t"); i >= 0 {
+		httpMethod, rest = rest[:i], strings.TrimLeft(rest[i+1:], " \t")
+		if httpMethod == "" {
+			panic("empty method in pattern '" + pattern + "'")
+		}
+	}
+	i := strings.IndexByte(rest, '/')
+	if i < 0 {
+		panic("host/path missing / in pattern '" + pattern + "'")
+	}
+	host, rest = rest[:i], rest[i:]
+
+	segments := strings.Split(rest[1:], "/")
+	var b strings.Builder
+	for idx, segment := range segments {
+		last := idx == len(segments)-1
+		b.WriteByte('/')
+		switch {
+		case segment == "{$}":
+			if !last {
+				panic("{$} not at end in pattern '" + pattern + "'")
+			}
+		case segment == "" && last:
+			// a trailing slash matches the whole subtree
+			if idx == 0 {
+				b.WriteString("*" + muxRestParam + "?")
+			} else {
+				b.WriteString("*" + muxRestParam)
+			}
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			name := segment[1 : len(segment)-1]
+			w := muxWildcard{name: strings.TrimSuffix(name, "...")}
+			w.rest = w.name != name
+			if w.rest && !last {
+				panic("{" + name + "} is not at the end in pattern '" + pattern + "'")
+			}
+			if !validParamName(w.name) {
+				panic("bad wildcard name '" + w.name + "' in pattern '" + pattern + "'")
+			}
+			for _, exist := range wildcards {
+				if exist.name == w.name {
+					panic("duplicate wildcard name '" + w.name + "' in pattern '" + pattern + "'")
+				}
+			}
+			wildcards = append(wildcards, w)
+			if w.rest {
+				b.WriteString("*" + w.name)
+			} else {
+				b.WriteString(":" + w.name)
+			}
+		case strings.ContainsAny(segment, "{}:*"):
+			panic("bad segment '" + segment + "' in pattern '" + pattern + "', wildcards must occupy a full path segment")
+		default:
+			b.WriteString(segment)
+		}
+	}
+	return httpMethod, host, b.String(), wildcards
+}
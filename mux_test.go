@@ -0,0 +1,151 @@
+package cart
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEngineHandleFunc(t *testing.T) {
+	app := New()
+	app.Use("/items", func(c *Context, next Next) {
+		id, _ := c.Param("id")
+		c.Header("X-Item", id)
+		next()
+	})
+	app.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "item %s", r.PathValue("id"))
+	})
+	app.HandleFunc("/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "file %q", r.PathValue("path"))
+	})
+	app.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "index")
+	})
+	app.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "static %s", r.URL.Path)
+	})
+	app.HandleFunc("POST api.example.com/users/{name}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "create %s", r.PathValue("name"))
+	})
+
+	tests := []struct {
+		method, host, path string
+		code               int
+		body               string
+	}{
+		{"GET", "", "/items/7", 200, "item 7"},
+		{"HEAD", "", "/items/7", 200, ""},
+		{"DELETE", "", "/items/7", 405, ""},
+		{"PUT", "", "/files/a/b.txt", 200, `file "a/b.txt"`},
+		{"GET", "", "/files/", 200, `file ""`},
+		{"GET", "", "/", 200, "index"},
+		{"GET", "", "/other", 404, ""},
+		{"GET", "", "/static/css/app.css", 200, "static /static/css/app.css"},
+		{"GET", "", "/static", 301, ""},
+		{"POST", "api.example.com", "/users/bob", 200, "create bob"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/items/7", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Header().Get("X-Item") != "7" {
+		t.Errorf("expected Context.Param in middleware, got %q", w.Header().Get("X-Item"))
+	}
+}
+
+func TestParseMuxPattern(t *testing.T) {
+	tests := []struct {
+		pattern, method, host, path string
+	}{
+		{"/", "ANY", "", "/*_?"},
+		{"GET /items/{id}", "GET", "", "/items/:id"},
+		{"DELETE  /items/{id}/", "DELETE", "", "/items/:id/*_"},
+		{"/files/{path...}", "ANY", "", "/files/*path"},
+		{"example.com/a/{$}", "ANY", "example.com", "/a/"},
+	}
+	for _, tt := range tests {
+		method, host, path, _ := parseMuxPattern(tt.pattern)
+		if method != tt.method || host != tt.host || path != tt.path {
+			t.Errorf("%q: expected %s %s %s, got %s %s %s", tt.pattern, tt.method, tt.host, tt.path, method, host, path)
+		}
+	}
+
+	for _, pattern := range []string{"items", "/{a}/{a}", "/{path...}/x", "/a{id}", "/{$}/x", "/a:b", "/{bad name}"} {
+		if recv := catchPanic(func() { parseMuxPattern(pattern) }); recv == nil {
+			t.Errorf("expected panic for pattern %q", pattern)
+		}
+	}
+}
+
+func TestEngineHandleFuncRootMiddleware(t *testing.T) {
+	for _, pattern := range []string{"/", "GET /"} {
+		app := New()
+		app.Use("/", func(c *Context, next Next) {
+			c.Header("X-Root", "1")
+			next()
+		})
+		if recv := catchPanic(func() {
+			app.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "root %s", r.URL.Path)
+			})
+		}); recv != nil {
+			t.Fatalf("%q: unexpected panic %v", pattern, recv)
+		}
+		for _, path := range []string{"/", "/a/b"} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			if w.Code != 200 || w.Body.String() != "root "+path || w.Header().Get("X-Root") != "1" {
+				t.Errorf("%q %s: got %d %q", pattern, path, w.Code, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestEngineHandleFuncWildcardNames(t *testing.T) {
+	app := New()
+	show := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s id=%s name=%s", r.Method, r.PathValue("id"), r.PathValue("name"))
+	}
+	app.HandleFunc("GET /items/{id}", show)
+	app.HandleFunc("DELETE /items/{name}", show)
+	app.HandleFunc("/items/{name}/edit", show)
+	app.HandleFunc("GET /users/{name}/", show)
+	app.HandleFunc("POST /users/{id}/{rest...}", show)
+	app.HandleFunc("GET /tags/{id}/{name}", show)
+	app.HandleFunc("POST /tags/{name}/{id}", show)
+
+	tests := []struct {
+		method, path, body string
+	}{
+		{"GET", "/items/7", "GET id=7 name="},
+		{"DELETE", "/items/7", "DELETE id= name=7"},
+		{"PUT", "/items/7/edit", "PUT id= name=7"},
+		{"GET", "/users/bob/x", "GET id= name=bob"},
+		{"POST", "/users/bob/x/y", "POST id=bob name="},
+		{"GET", "/tags/1/a", "GET id=1 name=a"},
+		{"POST", "/tags/a/1", "POST id=1 name=a"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != 200 || w.Body.String() != tt.body {
+			t.Errorf("%s %s: expected %q, got %d %q", tt.method, tt.path, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
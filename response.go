@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 )
 
 const (
@@ -18,6 +19,13 @@ type ResponseWriter struct {
 	size   int
 	status int
 	before []func()
+
+	// discardBody counts but drops body writes and holds the header back
+	// until WriteHeaderFinal, so HEAD responses keep their Content-Length.
+	discardBody bool
+
+	// call is the FromMiddleware call in progress on this writer
+	call *middlewareCall
 }
 
 // var _ ResponseWriter = &responseWriter{}
@@ -27,6 +35,8 @@ func (w *ResponseWriter) reset(writer http.ResponseWriter) {
 	w.size = noWritten
 	w.status = defaultStatus
 	w.before = nil
+	w.discardBody = false
+	w.call = nil
 }
 
 func (w *ResponseWriter) WriteHeader(code int) {
@@ -45,6 +55,15 @@ func (w *ResponseWriter) WriteHeaderFinal() {
 	if !w.Written() {
 		w.writeHeader()
 	}
+	if w.discardBody {
+		w.discardBody = false
+		header := w.Header()
+		if w.size > 0 && bodyAllowedForStatus(w.status) &&
+			header.Get("Content-Length") == "" && header.Get("Content-Encoding") == "" {
+			header.Set("Content-Length", strconv.Itoa(w.size))
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+	}
 }
 
 func (w *ResponseWriter) writeHeader() {
@@ -55,12 +74,19 @@ func (w *ResponseWriter) writeHeader() {
 		}
 		w.before = nil
 		w.size = 0
+		if w.discardBody {
+			return
+		}
 		w.ResponseWriter.WriteHeader(w.status)
 	}
 }
 
 func (w *ResponseWriter) Write(data []byte) (n int, err error) {
 	w.writeHeader()
+	if w.discardBody {
+		w.size += len(data)
+		return len(data), nil
+	}
 	n, err = w.ResponseWriter.Write(data)
 	w.size += n
 	return
@@ -68,6 +94,10 @@ func (w *ResponseWriter) Write(data []byte) (n int, err error) {
 
 func (w *ResponseWriter) WriteString(s string) (n int, err error) {
 	w.writeHeader()
+	if w.discardBody {
+		w.size += len(s)
+		return len(s), nil
+	}
 	n, err = io.WriteString(w.ResponseWriter, s)
 	w.size += n
 	return
@@ -99,6 +129,9 @@ func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 
 // Implements the http.Flush interface
 func (w *ResponseWriter) Flush() {
+	if w.discardBody {
+		return
+	}
 	if f, ok := w.ResponseWriter.(http.Flusher); ok {
 		f.Flush()
 	}
@@ -0,0 +1,172 @@
+package cart
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RewriteRule maps the requests whose path matches From, a route path that
+// may have params and a catch-all, to To, a path with an optional query. To
+// refers to the params of From as :name or *name. A zero Code rewrites the request before it is
+// routed, a 3xx Code redirects the client to the new path instead.
+type RewriteRule struct {
+	From string
+	To   string
+	Code int
+}
+
+// rewrite is a compiled RewriteRule.
+type rewrite struct {
+	path     []rewritePart
+	query    []rewritePart
+	hasQuery bool
+	code     int
+}
+
+// rewritePart is a static part of To, or the name of a param of From.
+type rewritePart struct {
+	text     string
+	param    bool
+	catchAll bool
+}
+
+// Rewrite adds rules that run before the route is matched, like Pre
+// handlers. When several rules match a path, the most specific one applies,
+// with the same precedence as routes. The query of the request is kept, after
+// the query of To if it has one.
+//
+//	app.Rewrite(
+//		cart.RewriteRule{From: "/old/:id", To: "/new/:id", Code: 301},
+//		cart.RewriteRule{From: "/docs/*page", To: "/manual/*page"},
+//	)
+func (e *Engine) Rewrite(rules ...RewriteRule) {
+	if len(rules) == 0 {
+		return
+	}
+	tree := &node{}
+	for _, rule := range rules {
+		tree.addRoute(rule.From, compileRewrite(rule))
+	}
+	tree.compress()
+
+	e.Pre(func(c *Context, next Next) {
+		path := c.Request.URL.Path
+		if e.UseRawPath {
+			path = c.Request.URL.EscapedPath()
+		}
+		ps := e.getParams()
+		handle, _ := tree.getValue(path, ps)
+		if handle == nil {
+			e.putParams(ps)
+			next()
+			return
+		}
+		rw := handle.(*rewrite)
+		to, query := expandRewrite(rw.path, *ps, false), expandRewrite(rw.query, *ps, true)
+		e.putParams(ps)
+		// a value like "//evil.com" must not turn the target into a
+		// protocol-relative URL of another host
+		to = "/" + strings.TrimLeft(to, "/\\")
+
+		u := c.Request.URL
+		if rw.code != 0 {
+			cp := *u
+			u = &cp
+		}
+		e.setPath(u, to)
+		if rw.hasQuery && u.RawQuery != "" {
+			u.RawQuery = query + "&" + u.RawQuery
+		} else if rw.hasQuery {
+			u.RawQuery = query
+		}
+		if rw.code == 0 {
+			debugPrint("Rewrite %s -> %s", path, u.RequestURI())
+			next()
+			return
+		}
+		http.Redirect(c.Response, c.Request, u.String(), rw.code)
+		c.Abort()
+	})
+}
+
+func compileRewrite(rule RewriteRule) *rewrite {
+	if rule.From == "" || rule.From[0] != '/' {
+		panic("Path must begin with '/' in path '" + rule.From + "'")
+	}
+	if rule.Code != 0 && (rule.Code < 300 || rule.Code > 399) {
+		panic(fmt.Sprintf("invalid redirect code %d in rewrite rule '%s'", rule.Code, rule.From))
+	}
+	if rule.To == "" || rule.To[0] != '/' {
+		panic("rewrite target must begin with '/' in rewrite rule '" + rule.From + "'")
+	}
+	kinds := make(map[string]nodeType)
+	segments := splitRoutePath(rule.From)
+	for i, segment := range segments {
+		seg := classifyRouteSegment(segment, rule.From, i == len(segments)-1)
+		if seg.kind != static {
+			kinds[seg.name] = seg.kind
+		}
+	}
+
+	to, query, hasQuery := strings.Cut(rule.To, "?")
+	return &rewrite{
+		path:     parseRewrite(rule, to, kinds),
+		query:    parseRewrite(rule, query, kinds),
+		hasQuery: hasQuery,
+		code:     rule.Code,
+	}
+}
+
+// parseRewrite splits s, the path or the query of rule.To, into its parts.
+func parseRewrite(rule RewriteRule, s string, kinds map[string]nodeType) []rewritePart {
+	var parts []rewritePart
+	for s != "" {
+		i := strings.IndexAny(s, ":*")
+		if i < 0 {
+			return append(parts, rewritePart{text: s})
+		}
+		if i > 0 {
+			parts = append(parts, rewritePart{text: s[:i]})
+		}
+		end := i + 1
+		for end < len(s) {
+			r, size := utf8.DecodeRuneInString(s[end:])
+			if r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			end += size
+		}
+		name := s[i+1 : end]
+		kind, ok := kinds[name]
+		if !ok {
+			panic("'" + s[i:end] + "' in rewrite target '" + rule.To + "' is not a wildcard of '" + rule.From + "'")
+		}
+		parts = append(parts, rewritePart{text: name, param: true, catchAll: kind == catchAll})
+		s = s[end:]
+	}
+	return parts
+}
+
+// expandRewrite substitutes the params of ps in parts, escaping them in a query.
+func expandRewrite(parts []rewritePart, ps Params, query bool) string {
+	var b strings.Builder
+	for _, part := range parts {
+		if !part.param {
+			b.WriteString(part.text)
+			continue
+		}
+		value, _ := ps.Get(part.text)
+		if query {
+			value = url.QueryEscape(value)
+		} else if part.catchAll && strings.HasSuffix(b.String(), "/") {
+			// a catch-all value starts with its own slash
+			value = strings.TrimPrefix(value, "/")
+		}
+		b.WriteString(value)
+	}
+	return b.String()
+}
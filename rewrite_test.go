@@ -0,0 +1,116 @@
+package cart
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnginePre(t *testing.T) {
+	app := New()
+	app.Pre(func(c *Context, next Next) {
+		if c.Request.Header.Get("X-Block") != "" {
+			c.AbortWithStatus(403)
+			return
+		}
+		if c.Request.URL.Path == "/legacy" {
+			c.Request.URL.Path = "/current"
+		}
+		next()
+	})
+	app.Route("/current").GET(func(c *Context) error {
+		c.String(200, "current "+c.Router.Path)
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/legacy", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 200 || w.Body.String() != "current /current" {
+		t.Errorf("expected the rewritten route, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/current", nil)
+	req.Header.Set("X-Block", "1")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 403 || w.Body.Len() != 0 {
+		t.Errorf("expected the pre handler to answer 403, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestEngineRewrite(t *testing.T) {
+	app := New()
+	app.Rewrite(
+		RewriteRule{From: "/old/:id", To: "/new/:id", Code: 301},
+		RewriteRule{From: "/old/special", To: "/new/special-case", Code: 302},
+		RewriteRule{From: "/u/:name/p/:id<int>", To: "/posts/:id?by=:name"},
+		RewriteRule{From: "/docs/*page", To: "/manual/*page"},
+		RewriteRule{From: "/files/*path", To: "/static*path"},
+	)
+	handler := func(c *Context) error {
+		c.String(200, c.Router.Path+" "+c.Request.URL.String())
+		return nil
+	}
+	app.Route("/posts/:id").GET(handler)
+	app.Route("/manual/*page").GET(handler)
+	app.Route("/static/*path").GET(handler)
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+		body     string
+	}{
+		{"/old/42?ref=mail", 301, "/new/42?ref=mail", ""},
+		{"/old/special", 302, "/new/special-case", ""},
+		{"/u/ann/p/7", 200, "", "/posts/:id /posts/7?by=ann"},
+		{"/u/a%26b/p/7?page=2", 200, "", "/posts/:id /posts/7?by=a%26b&page=2"},
+		{"/u/ann/p/x", 404, "", ""},
+		{"/docs/guide/intro", 200, "", "/manual/*page /manual/guide/intro"},
+		{"/files/css/app.css", 200, "", "/static/*path /static/css/app.css"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.code, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s: expected Location %q, got %q", tt.path, tt.location, loc)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: expected %q, got %q", tt.path, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestEngineRewritePanics(t *testing.T) {
+	tests := []RewriteRule{
+		{From: "/a/:id", To: "/b/:name"},
+		{From: "/a/:id", To: "b/:id"},
+		{From: "/a/:id", To: "/b/:id", Code: 200},
+		{From: "a", To: "/b"},
+	}
+	for _, rule := range tests {
+		if recv := catchPanic(func() { New().Rewrite(rule) }); recv == nil {
+			t.Errorf("expected a panic for %+v", rule)
+		}
+	}
+}
+
+func TestEngineRewriteOpenRedirect(t *testing.T) {
+	app := New()
+	app.Rewrite(RewriteRule{From: "/go/*page", To: "/*page", Code: 301})
+
+	for _, path := range []string{"/go//evil.com", "/go///evil.com/x", "/go/%2F%2Fevil.com", "/go/%5C%5Cevil.com"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		loc := w.Header().Get("Location")
+		if w.Code != 301 || !strings.HasPrefix(loc, "/") || strings.HasPrefix(loc, "//") || strings.HasPrefix(loc, "/\\") {
+			t.Errorf("%s: expected a local redirect, got %d %q", path, w.Code, loc)
+		}
+	}
+}
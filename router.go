@@ -1,101 +1,338 @@
 package cart
 
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
 type (
 	HandlerRoute func(*Router)
 
+	// methodUse is middleware that only runs for some methods.
+	methodUse struct {
+		methods []string
+		handler HandlerCompose
+		names   []string
+	}
 	method struct {
 		key     string
 		handler HandlerCompose
+		group   *group // Router.Group the handler was declared in
 	}
 	Router struct {
 		Engine          *Engine
 		Path            string
+		name            string
+		middleware      []string // names of the middleware in composed
 		composed        HandlerCompose
+		own             HandlerCompose // middleware registered on this path
+		ownNames        []string
+		ownFor          []methodUse // method middleware registered on this path
+		composedFor     []methodUse // method middleware of the parents and this path
 		methods         []method
 		flattenHandlers map[string]HandlerCompose // Pre-calculated handlers per method
+		table           *routeTable               // routers of the host r belongs to
+		trailingSlash   TrailingSlashPolicy
+		group           *group // set on the routers passed to Router.Group
 	}
 )
 
-func (r *Router) getMethod(httpMethod string) (HandlerCompose, bool) {
-	for _, entry := range r.methods {
-		if entry.key == httpMethod {
-			return entry.handler, true
+func (r *Router) getMethod(httpMethod string) (*method, bool) {
+	for i := range r.methods {
+		if r.methods[i].key == httpMethod {
+			return &r.methods[i], true
 		}
 	}
 	return nil, false
 }
 
 func (r *Router) flatten() {
-	if r.flattenHandlers == nil {
-		r.flattenHandlers = make(map[string]HandlerCompose)
-	}
-
-	baseComposed := r.composed
+	// a new map each time, routing snapshots keep reading the old one
+	handlers := make(map[string]HandlerCompose)
 
 	// 先获取 ANY handler
-	var anyHandler HandlerCompose
-	if mh, ok := r.getMethod("ANY"); ok {
-		anyHandler = mh
-	}
+	anyEntry, _ := r.getMethod("ANY")
 
+	// standard methods fall back to ANY and HEAD to GET, extension methods
+	// such as PROPFIND or QUERY are flattened once they are registered or
+	// have method middleware, and otherwise reach ANY through serveHTTP
 	methods := []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "HEAD", "CONNECT", "TRACE", "ANY"}
+	for _, entry := range r.methods {
+		if !slices.Contains(methods, entry.key) {
+			methods = append(methods, entry.key)
+		}
+	}
+	for _, u := range r.composedFor {
+		for _, m := range u.methods {
+			if !slices.Contains(methods, m) {
+				methods = append(methods, m)
+			}
+		}
+	}
 	for _, m := range methods {
-		var handler HandlerCompose
-		if mh, ok := r.getMethod(m); ok {
-			handler = mh
-		} else if m != "ANY" && anyHandler != nil {
+		// chainMethod selects the method middleware, GET middleware
+		// also runs for HEAD requests served by the GET handler
+		chainMethod := m
+		entry, ok := r.getMethod(m)
+		if !ok && m == "HEAD" {
+			// HEAD falls back to the GET chain, the body is discarded by ResponseWriter
+			entry, ok = r.getMethod("GET")
+			chainMethod = "GET"
+		}
+		if !ok && m != "ANY" && anyEntry != nil {
 			// 如果没有特定方法 handler 且有 ANY handler，使用 ANY handler
-			handler = anyHandler
+			entry, chainMethod = anyEntry, m
 		}
 
-		if baseComposed != nil {
-			if handler != nil {
-				r.flattenHandlers[m] = compose(baseComposed, handler)
-			} else {
-				// If no specific method handler, use base (middleware only)
-				r.flattenHandlers[m] = baseComposed
+		// Methods without a handler stay unset so serveHTTP can tell
+		// a middleware-only path (404) from a missing method (405).
+		if entry == nil {
+			continue
+		}
+		chain := make([]HandlerCompose, 0, 4)
+		for _, h := range []HandlerCompose{
+			r.composed,
+			r.methodChain(chainMethod),
+			entry.group.chain(r.Path, chainMethod),
+			entry.handler,
+		} {
+			if h != nil {
+				chain = append(chain, h)
 			}
-		} else if handler != nil {
-			r.flattenHandlers[m] = handler
 		}
+		handlers[m] = compose(chain...)
 	}
+	r.flattenHandlers = handlers
 }
 
-func (r *Router) use(absolutePath string, handler HandlerCompose) *Router {
-	next, find := r.Engine.getRouter(absolutePath)
-	if _, composed := r.Engine.mixComposed(absolutePath); composed != nil {
-		next.composed = compose(composed, handler)
-	} else {
-		next.composed = compose(handler)
+// methodChain returns the method middleware of r for httpMethod.
+func (r *Router) methodChain(httpMethod string) HandlerCompose {
+	var handlers []HandlerCompose
+	for _, u := range r.composedFor {
+		if u.methods == nil || slices.Contains(u.methods, httpMethod) {
+			handlers = append(handlers, u.handler)
+		}
+	}
+	return compose(handlers...)
+}
+
+// serves reports whether r has handlers, a router with only middleware does
+// not answer requests itself.
+func (r *Router) serves() bool {
+	return len(r.methods) > 0
+}
+
+// chainNames returns the names of the middleware that runs before the
+// handler registered for httpMethod, in execution order.
+func (r *Router) chainNames(entry *method) []string {
+	names := append([]string(nil), r.middleware...)
+	for _, u := range r.composedFor {
+		if u.methods == nil || slices.Contains(u.methods, entry.key) {
+			names = append(names, u.names...)
+		}
+	}
+	return append(names, entry.group.chainNames(r.Path, entry.key)...)
+}
+
+// allowed returns the Allow header value for the methods registered on r.
+func (r *Router) allowed() string {
+	seen := make(map[string]bool, len(r.methods))
+	allow := make([]string, 0, len(r.methods))
+	for _, entry := range r.methods {
+		if entry.key == "ANY" || seen[entry.key] {
+			continue
+		}
+		seen[entry.key] = true
+		allow = append(allow, entry.key)
+	}
+	if seen["GET"] && !seen["HEAD"] {
+		allow = append(allow, "HEAD")
+	}
+	if r.Engine != nil && r.Engine.HandleOPTIONS && !seen["OPTIONS"] {
+		allow = append(allow, "OPTIONS")
+	}
+	sort.Strings(allow)
+	return strings.Join(allow, ", ")
+}
+
+// use adds middleware at absolutePath, for every method when methods is nil.
+func (r *Router) use(absolutePath string, methods []string, handler HandlerCompose, names []string) *Router {
+	if r.group != nil {
+		r.group.use(r.Engine, absolutePath, methods, handler, names)
+		return r
 	}
+	e := r.Engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.modify()
+	next, find := r.lookup(absolutePath)
+	next.addMiddleware(methods, handler, names)
 	if !find {
-		r.Engine.addRoute(next)
-	} else {
-		// 关键修复：即使路由已存在，也要重新计算 handler 链
-		next.flatten()
+		e.addRoute(next)
 	}
+	// routes registered below absolutePath before this call get it too
+	e.recomposeBelow(r.table, absolutePath)
 	return next
 }
 
 func (r *Router) handle(httpMethod, absolutePath string, handler HandlerCompose) *Router {
-	next, find := r.Engine.getRouter(absolutePath)
-	if _, composed := r.Engine.mixComposed(absolutePath); composed != nil {
-		next.composed = compose(composed)
+	if !validMethod(httpMethod) {
+		panic("invalid http method '" + httpMethod + "' for path '" + absolutePath + "'")
+	}
+	e := r.Engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.modify()
+	next, find := r.lookup(absolutePath)
+	if find && !next.serves() && r.table.tree.hasOptionalChild(absolutePath) {
+		panic("path '" + absolutePath + "' conflicts with the optional wildcard of an existing route")
 	}
-	method := method{key: httpMethod, handler: handler}
+	method := method{key: httpMethod, handler: handler, group: r.group}
 	next.methods = append(next.methods, method)
 	if !find {
-		r.Engine.addRoute(next)
+		e.addRoute(next)
 	} else {
 		// 关键修复：即使路由已存在，也要重新计算 handler 链
 		next.flatten()
 	}
+	if next != r && r.name != "" && next.name == "" && (r.group != nil || absolutePath == r.Path) {
+		// r was named before its path had a router
+		next.name = r.name
+	}
+	if next.name != "" && e.names[next.name] == r {
+		e.names[next.name] = next
+	}
+	if r.group != nil {
+		r.group.add(next)
+		return r
+	}
 	return next
 }
 
+// RemoveRoute removes the handler of httpMethod registered at relativePath,
+// or all of its handlers when httpMethod is empty. A path left without
+// handlers and middleware is removed from the tree.
+func (r *Router) RemoveRoute(relativePath, httpMethod string) error {
+	absolutePath := joinPaths(r.Path, relativePath)
+	e := r.Engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	router := r.table.routers[absolutePath]
+	if router == nil {
+		return fmt.Errorf("route %s not found", absolutePath)
+	}
+	methods := make([]method, 0, len(router.methods))
+	for _, entry := range router.methods {
+		if httpMethod == "" || entry.key == httpMethod {
+			continue
+		}
+		methods = append(methods, entry)
+	}
+	if len(methods) == len(router.methods) {
+		return fmt.Errorf("route %s %s not found", httpMethod, absolutePath)
+	}
+	e.modify()
+	removed := router.methods
+	router.methods = methods
+	for _, entry := range removed {
+		entry.group.remove(router, methods)
+	}
+	if len(methods) > 0 || router.own != nil || len(router.ownFor) > 0 {
+		router.flatten()
+		return nil
+	}
+	debugPrint("Remove Router %s", absolutePath)
+	r.table.tree.removeRoute(absolutePath)
+	delete(r.table.routers, absolutePath)
+	for name, named := range e.names {
+		if named.table == r.table && named.Path == absolutePath {
+			delete(e.names, name)
+		}
+	}
+	return nil
+}
+
+// ReplaceRoute swaps the handler of httpMethod registered at relativePath
+// for handler, requests see either the old or the new one.
+func (r *Router) ReplaceRoute(relativePath, httpMethod string, handler HandlerFinal) error {
+	absolutePath := joinPaths(r.Path, relativePath)
+	e := r.Engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	router := r.table.routers[absolutePath]
+	if router == nil {
+		return fmt.Errorf("route %s not found", absolutePath)
+	}
+	i := slices.IndexFunc(router.methods, func(m method) bool { return m.key == httpMethod })
+	if i < 0 {
+		return fmt.Errorf("route %s %s not found", httpMethod, absolutePath)
+	}
+	e.modify()
+	methods := slices.Clone(router.methods)
+	methods[i].handler = r.finalCompose(handler)
+	router.methods = methods
+	router.flatten()
+	return nil
+}
+
+// lookup returns the router registered at absolutePath in the table of r or
+// a new one that inherits the trailing slash policy of r. It must be called
+// with e.mu held.
+func (r *Router) lookup(absolutePath string) (*Router, bool) {
+	if next := r.table.routers[absolutePath]; next != nil {
+		return next, true
+	}
+	next := &Router{
+		Engine:  r.Engine,
+		Path:    absolutePath,
+		methods: make([]method, 0),
+		table:   r.table,
+	}
+	if absolutePath == r.Path {
+		next.trailingSlash = r.trailingSlash
+	}
+	return next, false
+}
+
+func (r *Router) addMiddleware(methods []string, handler HandlerCompose, names []string) {
+	if methods != nil {
+		r.ownFor = append(r.ownFor, methodUse{methods: methods, handler: handler, names: names})
+		return
+	}
+	if r.own != nil {
+		handler = compose(r.own, handler)
+	}
+	r.own = handler
+	r.ownNames = append(r.ownNames, names...)
+}
+
+// recompose rebuilds the middleware chain of r from the chain of parent
+// followed by r's own middleware, then flattens the handlers.
+func (r *Router) recompose(parent *Router) {
+	r.composed = r.own
+	r.middleware = r.ownNames
+	r.composedFor = r.ownFor
+	if parent != nil && len(parent.composedFor) > 0 {
+		r.composedFor = append(slices.Clip(parent.composedFor), r.ownFor...)
+	}
+	if parent != nil && parent.composed != nil {
+		r.composed = parent.composed
+		if r.own != nil {
+			r.composed = compose(parent.composed, r.own)
+		}
+		r.middleware = append(append([]string(nil), parent.middleware...), r.ownNames...)
+	}
+	r.flatten()
+}
+
 func (r *Router) Route(relativePath string, handles ...HandlerRoute) *Router {
 	absolutePath := joinPaths(r.Path, relativePath)
-	next, _ := r.Engine.getRouter(absolutePath)
+	next, _ := r.Engine.getRouter(r.table, absolutePath)
+	if r.group != nil {
+		next = r.groupRouter(absolutePath, r.group)
+	}
 	for _, handle := range handles {
 		handle(next)
 	}
@@ -104,15 +341,55 @@ func (r *Router) Route(relativePath string, handles ...HandlerRoute) *Router {
 
 func (r *Router) Use(relativePath string, handles ...Handler) *Router {
 	absolutePath := joinPaths(r.Path, relativePath)
-	next := r.use(absolutePath, makeCompose(handles...))
+	names := make([]string, 0, len(handles))
+	for _, handle := range handles {
+		names = append(names, nameOfFunction(handle))
+	}
+	next := r.use(absolutePath, nil, makeCompose(handles...), names)
 	return next
 }
 
+// UseFor adds middleware to the path of r that only runs for the given
+// methods. It runs after the middleware added with Use.
+func (r *Router) UseFor(methods []string, handles ...Handler) *Router {
+	if len(methods) == 0 {
+		return r
+	}
+	for _, m := range methods {
+		if !validMethod(m) {
+			panic("invalid http method '" + m + "' for path '" + r.Path + "'")
+		}
+	}
+	names := make([]string, 0, len(handles))
+	for _, handle := range handles {
+		names = append(names, nameOfFunction(handle))
+	}
+	return r.use(r.Path, slices.Clone(methods), makeCompose(handles...), names)
+}
+
+// SetTrailingSlash overrides Engine.TrailingSlash for requests that match
+// the path of r only with or without a trailing slash.
+func (r *Router) SetTrailingSlash(policy TrailingSlashPolicy) *Router {
+	e := r.Engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.modify()
+	r.trailingSlash = policy
+	if router := r.table.routers[r.Path]; router != nil {
+		router.trailingSlash = policy
+	}
+	return r
+}
+
 func (r *Router) ANY(handler Handler) *Router {
 	return r.handle("ANY", r.Path, makeCompose(handler))
 }
 
 func (r *Router) Handle(httpMethod string, handler HandlerFinal) *Router {
+	return r.handle(httpMethod, r.Path, r.finalCompose(handler))
+}
+
+func (r *Router) finalCompose(handler HandlerFinal) HandlerCompose {
 	tempHandler := func(c *Context, next Next) {
 		if err := handler(c); err != nil {
 			if r.Engine.ErrorHandler != nil {
@@ -122,7 +399,7 @@ func (r *Router) Handle(httpMethod string, handler HandlerFinal) *Router {
 			}
 		}
 	}
-	return r.handle(httpMethod, r.Path, makeCompose(tempHandler))
+	return makeCompose(tempHandler)
 }
 
 func (r *Router) GET(handler HandlerFinal) *Router {
@@ -2,6 +2,7 @@ package cart
 
 import (
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -54,3 +55,308 @@ func TestRouterMethods(t *testing.T) {
 		}
 	}
 }
+
+func TestMethodNotAllowed(t *testing.T) {
+	app := New()
+	var passed bool
+	app.Use("/", func(c *Context, next Next) {
+		passed = true
+		next()
+	})
+	app.Route("/users").GET(func(c *Context) error {
+		c.String(200, "list")
+		return nil
+	})
+	app.Route("/users").POST(func(c *Context) error {
+		c.String(201, "create")
+		return nil
+	})
+
+	req := httptest.NewRequest("DELETE", "/users", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != 405 {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("Expected Allow 'GET, HEAD, OPTIONS, POST', got %q", allow)
+	}
+	if !passed {
+		t.Error("Expected middleware to run before 405")
+	}
+
+	// unknown path is still 404
+	req = httptest.NewRequest("DELETE", "/nothing", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+func TestMethodNotAllowedCustom(t *testing.T) {
+	app := New()
+	app.MethodNotAllowed = func(c *Context) error {
+		c.JSON(405, H{"error": "method not allowed"})
+		return nil
+	}
+	app.Route("/items").GET(func(c *Context) error {
+		return nil
+	})
+
+	req := httptest.NewRequest("PUT", "/items", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != 405 {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("Expected Allow 'GET, HEAD, OPTIONS', got %q", allow)
+	}
+	if !strings.Contains(w.Body.String(), "method not allowed") {
+		t.Errorf("Unexpected body %s", w.Body.String())
+	}
+}
+
+func TestMiddlewareOnlyNotFound(t *testing.T) {
+	app := New()
+	app.Use("/mw", func(c *Context, next Next) { next() })
+
+	req := httptest.NewRequest("GET", "/mw", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("Expected 404 for middleware-only path, got %d", w.Code)
+	}
+}
+
+func TestAutoOPTIONS(t *testing.T) {
+	app := New()
+	handler := func(c *Context) error {
+		c.String(200, "ok")
+		return nil
+	}
+	app.Route("/auto").GET(handler).POST(handler)
+	app.Route("/custom").GET(handler).OPTIONS(func(c *Context) error {
+		c.String(200, "custom options")
+		return nil
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/auto", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 204 {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("Expected Allow 'GET, HEAD, OPTIONS, POST', got %q", allow)
+	}
+
+	req = httptest.NewRequest("OPTIONS", "/custom", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Body.String() != "custom options" {
+		t.Errorf("Expected explicit OPTIONS handler, got %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest("OPTIONS", "/missing", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("Expected 404 for unknown path, got %d", w.Code)
+	}
+}
+
+func TestAutoOPTIONSDisabled(t *testing.T) {
+	app := New()
+	app.HandleOPTIONS = false
+	app.Route("/auto").GET(func(c *Context) error {
+		return nil
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/auto", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 405 {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("Expected Allow 'GET, HEAD', got %q", allow)
+	}
+}
+
+func TestAutoOPTIONSWithCORS(t *testing.T) {
+	app := New()
+	app.Use("/", CORS())
+	app.Route("/api").POST(func(c *Context) error {
+		return nil
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/api", nil)
+	req.Header.Set("Origin", "http://example.com")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 204 {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Error("Expected CORS preflight to be answered by the middleware")
+	}
+	if w.Header().Get("Allow") != "" {
+		t.Error("Expected CORS to short-circuit the automatic OPTIONS response")
+	}
+}
+
+func TestAutoHEAD(t *testing.T) {
+	app := New()
+	app.Route("/users").GET(func(c *Context) error {
+		c.Header("X-Total", "2")
+		c.String(200, "alice,bob")
+		return nil
+	})
+	app.Route("/explicit").GET(func(c *Context) error {
+		c.String(200, "get")
+		return nil
+	}).HEAD(func(c *Context) error {
+		c.Header("X-Head", "1")
+		c.Status(204)
+		return nil
+	})
+
+	req := httptest.NewRequest("HEAD", "/users", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body for HEAD, got %q", w.Body.String())
+	}
+	if w.Header().Get("X-Total") != "2" {
+		t.Error("Expected headers of the GET handler to be kept")
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "9" {
+		t.Errorf("Expected Content-Length 9, got %q", cl)
+	}
+
+	req = httptest.NewRequest("HEAD", "/explicit", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 204 || w.Header().Get("X-Head") != "1" {
+		t.Errorf("Expected explicit HEAD handler, got %d", w.Code)
+	}
+
+	// GET wins over ANY for HEAD
+	app.Route("/mixed").GET(func(c *Context) error {
+		c.String(200, "get body")
+		return nil
+	}).ANY(func(c *Context, next Next) {
+		c.String(200, "any")
+	})
+	req = httptest.NewRequest("HEAD", "/mixed", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if cl := w.Header().Get("Content-Length"); w.Code != 200 || cl != "8" || w.Body.Len() != 0 {
+		t.Errorf("Expected the bodiless GET response, got %d Content-Length %q", w.Code, cl)
+	}
+
+	req = httptest.NewRequest("HEAD", "/missing", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 404 || w.Body.Len() != 0 {
+		t.Errorf("Expected bodiless 404, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRouteParamConstraints(t *testing.T) {
+	app := New()
+	app.Route("/users/:id<int>").GET(func(c *Context) error {
+		id, _ := c.ParamInt("id")
+		c.String(200, "id %d", id)
+		return nil
+	})
+	app.Route("/users/:name").GET(func(c *Context) error {
+		name, _ := c.Param("name")
+		c.String(200, "name %s", name)
+		return nil
+	})
+	app.Route("/orders/:id<int>").GET(func(c *Context) error {
+		return nil
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/12", 200, "id 12"},
+		{"/users/bob", 200, "name bob"},
+		{"/orders/abc", 404, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("GET %s expected %d, got %d", tt.path, tt.code, w.Code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("GET %s expected body %q, got %q", tt.path, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestExtensionMethods(t *testing.T) {
+	app := New()
+	app.Route("/dav/file").
+		Handle("PROPFIND", func(c *Context) error {
+			c.String(207, "propfind")
+			return nil
+		}).
+		GET(func(c *Context) error {
+			c.String(200, "get")
+			return nil
+		})
+	app.Route("/search").Handle("QUERY", func(c *Context) error {
+		c.String(200, "query")
+		return nil
+	})
+	app.Route("/cache").ANY(func(c *Context, next Next) {
+		c.String(200, "any %s", c.Request.Method)
+	})
+
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{"PROPFIND", "/dav/file", 207, "propfind"},
+		{"QUERY", "/search", 200, "query"},
+		{"PURGE", "/cache", 200, "any PURGE"},
+		{"MKCOL", "/dav/file", 405, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.body, w.Body.String())
+		}
+		if tt.code == 405 {
+			if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, PROPFIND" {
+				t.Errorf("unexpected Allow header %q", allow)
+			}
+		}
+	}
+
+	if recv := catchPanic(func() {
+		app.Route("/bad").Handle("BAD METHOD", func(c *Context) error { return nil })
+	}); recv == nil {
+		t.Error("expected panic for an invalid method token")
+	}
+}
@@ -1,6 +1,9 @@
 package cart
 
 import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -49,3 +52,369 @@ func TestRouterFlatten(t *testing.T) {
 		t.Error("/v1/user GET handler not flattened")
 	}
 }
+
+func auditMiddleware(c *Context, next Next) { next() }
+
+func TestEngineRoutes(t *testing.T) {
+	e := New()
+	noop := func(c *Context) error { return nil }
+
+	e.Use("/", auditMiddleware)
+	e.Use("/admin", auditMiddleware, func(c *Context, next Next) { next() })
+	e.Route("/users/:id").GET(noop).PUT(noop).Name("user.show")
+	e.Route("/admin/stats").GET(noop)
+
+	routes := e.Routes()
+	paths := make([]string, 0, len(routes))
+	for _, route := range routes {
+		paths = append(paths, route.Path)
+	}
+	want := []string{"/", "/admin", "/admin/stats", "/users/:id"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("Routes() paths = %v, want %v", paths, want)
+	}
+
+	root := routes[0]
+	if !root.MiddlewareOnly || len(root.Methods) != 0 {
+		t.Errorf("expected / to be middleware only, got %+v", root)
+	}
+	if len(root.Middleware) != 1 || !strings.HasSuffix(root.Middleware[0], "auditMiddleware") {
+		t.Errorf("unexpected middleware names for /: %v", root.Middleware)
+	}
+
+	stats := routes[2]
+	if stats.MiddlewareOnly || !reflect.DeepEqual(stats.Methods, []string{"GET"}) {
+		t.Errorf("unexpected /admin/stats route %+v", stats)
+	}
+	if len(stats.Middleware) != 3 {
+		t.Errorf("expected 3 middleware for /admin/stats, got %v", stats.Middleware)
+	}
+
+	user := routes[3]
+	if user.Name != "user.show" || !reflect.DeepEqual(user.Methods, []string{"GET", "PUT"}) {
+		t.Errorf("unexpected /users/:id route %+v", user)
+	}
+	if len(user.Middleware) != 1 {
+		t.Errorf("expected 1 middleware for /users/:id, got %v", user.Middleware)
+	}
+}
+
+func csrfMiddleware(c *Context, next Next)  { next() }
+func limitMiddleware(c *Context, next Next) { next() }
+
+func TestEngineRoutesMethodMiddleware(t *testing.T) {
+	e := New()
+	noop := func(c *Context) error { return nil }
+
+	e.Use("/f", auditMiddleware)
+	e.Route("/f").UseFor([]string{"POST"}, csrfMiddleware).GET(noop)
+	e.Group(func(g *Router) {
+		g.Use("/", limitMiddleware)
+		g.Route("/f").POST(noop)
+	})
+
+	var f RouteInfo
+	for _, route := range e.Routes() {
+		if route.Path == "/f" {
+			f = route
+		}
+	}
+	short := func(names []string) []string {
+		out := make([]string, len(names))
+		for i, name := range names {
+			out[i] = name[strings.LastIndexByte(name, '.')+1:]
+		}
+		return out
+	}
+	if got := short(f.Middleware); !reflect.DeepEqual(got, []string{"auditMiddleware"}) {
+		t.Errorf("Middleware = %v", got)
+	}
+	if got := short(f.MethodMiddleware["GET"]); !reflect.DeepEqual(got, []string{"auditMiddleware"}) {
+		t.Errorf("GET middleware = %v", got)
+	}
+	want := []string{"auditMiddleware", "csrfMiddleware", "limitMiddleware"}
+	if got := short(f.MethodMiddleware["POST"]); !reflect.DeepEqual(got, want) {
+		t.Errorf("POST middleware = %v, want %v", got, want)
+	}
+
+	e.Route("/only").UseFor([]string{"PUT"}, csrfMiddleware)
+	var only RouteInfo
+	for _, route := range e.Routes() {
+		if route.Path == "/only" {
+			only = route
+		}
+	}
+	if !only.MiddlewareOnly || len(only.Methods) != 0 || len(only.Middleware) != 0 {
+		t.Errorf("expected /only to be middleware only, got %+v", only)
+	}
+	if got := short(only.MethodMiddleware["PUT"]); !reflect.DeepEqual(got, []string{"csrfMiddleware"}) {
+		t.Errorf("PUT middleware of /only = %v", got)
+	}
+}
+
+func TestMiddlewareRegistrationOrder(t *testing.T) {
+	var trace []string
+	mark := func(name string) Handler {
+		return func(c *Context, next Next) {
+			trace = append(trace, name)
+			next()
+		}
+	}
+	final := func(c *Context) error {
+		trace = append(trace, "handler")
+		return nil
+	}
+
+	e := New()
+	// routes first, middleware afterwards
+	e.Route("/api/users").GET(final)
+	e.Route("/api/users/:id").GET(final)
+	e.Route("/api").GET(final)
+	e.Route("/public").GET(final)
+	e.Use("/api/users", mark("users"))
+	e.Use("/api", mark("auth"))
+	e.Use("/", mark("root"))
+	e.Use("/api", mark("audit"))
+	e.Use("/api/", mark("slash"))
+
+	tests := []struct {
+		path  string
+		trace string
+	}{
+		{"/api/users", "root slash auth audit users handler"},
+		{"/api/users/1", "root slash auth audit users handler"},
+		{"/api", "root slash auth audit handler"},
+		{"/public", "root handler"},
+		{"/api/missing", "root slash auth audit"},
+	}
+	for _, tt := range tests {
+		trace = nil
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if got := strings.Join(trace, " "); got != tt.trace {
+			t.Errorf("%s: expected %q, got %q", tt.path, tt.trace, got)
+		}
+	}
+}
+
+func TestMiddlewareHostOrder(t *testing.T) {
+	var trace []string
+	e := New()
+	api := e.Host("api.example.com")
+	api.Route("/v1/items").GET(func(c *Context) error {
+		trace = append(trace, "handler")
+		return nil
+	})
+	api.Use("/v1", func(c *Context, next Next) {
+		trace = append(trace, "v1")
+		next()
+	})
+	e.Use("/", func(c *Context, next Next) {
+		trace = append(trace, "default")
+		next()
+	})
+
+	req := httptest.NewRequest("GET", "/v1/items", nil)
+	req.Host = "api.example.com"
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	if got := strings.Join(trace, " "); got != "v1 handler" {
+		t.Errorf("expected host middleware only, got %q", got)
+	}
+}
+
+func TestRouterUseFor(t *testing.T) {
+	var trace []string
+	mark := func(name string) Handler {
+		return func(c *Context, next Next) {
+			trace = append(trace, name)
+			next()
+		}
+	}
+	final := func(c *Context) error {
+		trace = append(trace, "handler")
+		return nil
+	}
+
+	e := New()
+	e.Route("/forms/:id").GET(final).PUT(final).DELETE(final)
+	e.Route("/forms").UseFor([]string{"POST", "PUT", "DELETE"}, mark("csrf"))
+	e.Route("/forms").POST(final)
+	e.Use("/", mark("log"))
+	e.Route("/forms/:id").UseFor([]string{"PURGE"}, mark("purge"))
+	e.Route("/forms/:id").ANY(func(c *Context, next Next) {
+		trace = append(trace, "any")
+	})
+	e.Group(func(g *Router) {
+		g.UseFor([]string{"POST"}, mark("group"))
+		g.Route("/grouped").POST(final).GET(final)
+	})
+
+	tests := []struct {
+		method, path string
+		trace        string
+	}{
+		{"GET", "/forms/1", "log handler"},
+		{"HEAD", "/forms/1", "log handler"},
+		{"PUT", "/forms/1", "log csrf handler"},
+		{"DELETE", "/forms/1", "log csrf handler"},
+		{"POST", "/forms", "log csrf handler"},
+		{"POST", "/forms/1", "log csrf any"},
+		{"PURGE", "/forms/1", "log purge any"},
+		{"PATCH", "/forms/1", "log any"},
+		{"POST", "/grouped", "log group handler"},
+		{"GET", "/grouped", "log handler"},
+	}
+	for _, tt := range tests {
+		trace = nil
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		if got := strings.Join(trace, " "); got != tt.trace {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.trace, got)
+		}
+	}
+}
+
+func TestEngineRemoveRoute(t *testing.T) {
+	e := New()
+	final := func(body string) HandlerFinal {
+		return func(c *Context) error {
+			c.String(200, body)
+			return nil
+		}
+	}
+	e.Route("/users/:id").GET(final("get")).DELETE(final("delete")).Name("user")
+	e.Use("/admin", func(c *Context, next Next) { next() })
+	e.Route("/admin/stats").GET(final("stats"))
+	e.Route("/admin").GET(final("admin"))
+
+	serve := func(method, path string) (int, string) {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	if err := e.RemoveRoute("/users/:id", "DELETE"); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := serve("DELETE", "/users/1"); code != 405 {
+		t.Errorf("expected 405 after removing DELETE, got %d", code)
+	}
+	if code, body := serve("GET", "/users/1"); code != 200 || body != "get" {
+		t.Errorf("expected GET to stay, got %d %q", code, body)
+	}
+
+	if err := e.RemoveRoute("/users/:id", ""); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := serve("GET", "/users/1"); code != 404 {
+		t.Errorf("expected 404 after removing the path, got %d", code)
+	}
+	if _, ok := e.findRouter("/users/:id"); ok {
+		t.Error("expected router to be dropped")
+	}
+	if _, err := e.URL("user", 1); err == nil {
+		t.Error("expected the route name to be dropped")
+	}
+
+	// a path with middleware stays as a middleware-only route
+	if err := e.RemoveRoute("/admin", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.findRouter("/admin"); !ok {
+		t.Error("expected middleware router to stay")
+	}
+	if code, _ := serve("GET", "/admin/stats"); code != 200 {
+		t.Errorf("expected child route to stay, got %d", code)
+	}
+
+	if err := e.RemoveRoute("/missing", "GET"); err == nil || err.Error() != "route /missing not found" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := e.RemoveRoute("/admin/stats", "POST"); err == nil || err.Error() != "route POST /admin/stats not found" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// removed paths can be registered again
+	e.Route("/users/:name").GET(final("again"))
+	if code, body := serve("GET", "/users/bob"); code != 200 || body != "again" {
+		t.Errorf("expected re-registered route, got %d %q", code, body)
+	}
+}
+
+func TestEngineReplaceRoute(t *testing.T) {
+	e := New()
+	var trace []string
+	e.Use("/", func(c *Context, next Next) {
+		trace = append(trace, "mw")
+		next()
+	})
+	e.Route("/config").GET(func(c *Context) error {
+		c.String(200, "v1")
+		return nil
+	})
+
+	if err := e.ReplaceRoute("/config", "GET", func(c *Context) error {
+		c.String(200, "v2")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/config", nil))
+	if w.Body.String() != "v2" || strings.Join(trace, " ") != "mw" {
+		t.Errorf("expected replaced handler behind the middleware, got %q %v", w.Body.String(), trace)
+	}
+	if err := e.ReplaceRoute("/config", "POST", nil); err == nil {
+		t.Error("expected error for a method that is not registered")
+	}
+}
+
+func TestOptionalWildcardWithParentMiddleware(t *testing.T) {
+	mw := func(c *Context, next Next) {
+		c.Header("X-Mw", "1")
+		next()
+	}
+	show := func(c *Context) error {
+		path, _ := c.Param("path")
+		c.String(200, "docs "+path)
+		return nil
+	}
+	register := map[string]func(e *Engine){
+		"use first": func(e *Engine) {
+			e.Use("/docs", mw)
+			e.Route("/docs/*path?").GET(show)
+		},
+		"route first": func(e *Engine) {
+			e.Route("/docs/*path?").GET(show)
+			e.Use("/docs", mw)
+		},
+	}
+	for name, fn := range register {
+		e := New()
+		if recv := catchPanic(func() { fn(e) }); recv != nil {
+			t.Fatalf("%s: unexpected panic %v", name, recv)
+		}
+		for path, want := range map[string]string{"/docs": "docs ", "/docs/a/b": "docs /a/b"} {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			if w.Code != 200 || w.Body.String() != want || w.Header().Get("X-Mw") != "1" {
+				t.Errorf("%s %s: got %d %q mw=%q", name, path, w.Code, w.Body.String(), w.Header().Get("X-Mw"))
+			}
+		}
+		// a handler on the parent path still conflicts
+		if recv := catchPanic(func() { e.Route("/docs").GET(show) }); recv == nil {
+			t.Errorf("%s: expected a conflict for a handler on /docs", name)
+		}
+	}
+
+	e := New()
+	e.Use("/", mw)
+	if recv := catchPanic(func() { e.Route("/:page?").GET(show) }); recv != nil {
+		t.Fatalf("unexpected panic for /:page? below root middleware: %v", recv)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 200 || w.Header().Get("X-Mw") != "1" {
+		t.Errorf("expected / to be served by /:page?, got %d", w.Code)
+	}
+}
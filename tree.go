@@ -1,7 +1,10 @@
 package cart
 
 import (
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
@@ -23,6 +26,48 @@ type node struct {
 	priority  uint32
 	children  []*node
 	handle    interface{}
+
+	// constraint is the raw <...> expression of a param node, check validates a value against it
+	constraint string
+	check      func(string) bool
+	// prefix and suffix are the static parts around a param inside its segment
+	prefix string
+	suffix string
+	// optional wildcards also match when their segment is missing
+	optional bool
+}
+
+// clone returns a deep copy of n with every handle h replaced by handle(h).
+func (n *node) clone(handle func(interface{}) interface{}) *node {
+	cp := *n
+	if n.handle != nil {
+		cp.handle = handle(n.handle)
+	}
+	if n.children != nil {
+		cp.children = make([]*node, len(n.children))
+		for i, child := range n.children {
+			cp.children[i] = child.clone(handle)
+		}
+	}
+	return &cp
+}
+
+// compress merges every static node without a handle into its only child when
+// that child is static too, so a chain of segments like "api/v1" is matched
+// as one node. A compressed tree is only matched, routes are not added to or
+// removed from it.
+func (n *node) compress() {
+	for n.nType == static && n.handle == nil && len(n.children) == 1 && n.children[0].nType == static {
+		child := n.children[0]
+		n.path += "/" + child.path
+		n.indices = child.indices
+		n.wildChild = child.wildChild
+		n.children = child.children
+		n.handle = child.handle
+	}
+	for _, child := range n.children {
+		child.compress()
+	}
 }
 
 func (n *node) addRoute(path string, handle interface{}) {
@@ -35,15 +80,24 @@ func (n *node) addRoute(path string, handle interface{}) {
 
 	segments := splitRoutePath(path)
 	cur := n
+	optional := false
 	for i, segment := range segments {
-		kind, name := classifyRouteSegment(segment, path, i == len(segments)-1)
-		switch kind {
+		seg := classifyRouteSegment(segment, path, i == len(segments)-1)
+		if seg.optional {
+			// the route also answers without its last segment, so the parent
+			// must not serve requests or have another optional child of its own
+			if handleServes(cur.handle) || cur.optionalChild() != nil {
+				panic("optional wildcard '" + segment + "' in new path '" + path + "' conflicts with an existing route without it")
+			}
+			optional = true
+		}
+		switch seg.kind {
 		case static:
 			cur = cur.staticChildOrCreate(segment)
 		case param:
-			cur = cur.paramChildOrCreate(name, path)
+			cur = cur.paramChildOrCreate(seg, path)
 		case catchAll:
-			cur = cur.catchAllChildOrCreate(name, path)
+			cur = cur.catchAllChildOrCreate(seg.name, path)
 		default:
 			panic("invalid node type")
 		}
@@ -51,8 +105,89 @@ func (n *node) addRoute(path string, handle interface{}) {
 	if cur.handle != nil {
 		panic("a handle is already registered for path '" + path + "'")
 	}
+	if !optional && handleServes(handle) && cur.optionalChild() != nil {
+		panic("path '" + path + "' conflicts with the optional wildcard of an existing route")
+	}
 	cur.handle = handle
+	cur.optional = optional
+	n.recomputePriority()
+}
+
+// handleServes reports whether handle answers requests. A handle may carry
+// only middleware, like the router of a Use path, and then leaves its path to
+// an optional wildcard below it.
+func handleServes(handle interface{}) bool {
+	if handle == nil {
+		return false
+	}
+	if h, ok := handle.(interface{ serves() bool }); ok {
+		return h.serves()
+	}
+	return true
+}
+
+// hasOptionalChild reports whether the node of path, written as it was
+// registered, has an optional wildcard child.
+func (n *node) hasOptionalChild(path string) bool {
+	trail := n.trail(path)
+	return trail != nil && trail[len(trail)-1].optionalChild() != nil
+}
+
+// trail returns the nodes from n to the node of path, which must be written
+// as it was registered, or nil if path has no node.
+func (n *node) trail(path string) []*node {
+	segments := splitRoutePath(path)
+	trail := make([]*node, 1, len(segments)+1)
+	trail[0] = n
+	cur := n
+	for i, segment := range segments {
+		seg := classifyRouteSegment(segment, path, i == len(segments)-1)
+		var next *node
+		switch seg.kind {
+		case static:
+			next = cur.staticChild(segment)
+		case param:
+			next = cur.paramChild(seg)
+		case catchAll:
+			if child := cur.catchAllChild(); child != nil && child.path == seg.name {
+				next = child
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		cur = next
+		trail = append(trail, cur)
+	}
+	return trail
+}
+
+// removeRoute removes the handle of path, which must be written as it was
+// registered, and prunes the nodes left without handles or children.
+// It returns the removed handle, or nil if path has none.
+func (n *node) removeRoute(path string) interface{} {
+	trail := n.trail(path)
+	if trail == nil {
+		return nil
+	}
+	cur := trail[len(trail)-1]
+	handle := cur.handle
+	if handle == nil {
+		return nil
+	}
+	cur.handle = nil
+	cur.optional = false
+	for i := len(trail) - 1; i > 0; i-- {
+		child := trail[i]
+		if child.handle != nil || len(child.children) > 0 {
+			break
+		}
+		parent := trail[i-1]
+		parent.children = slices.DeleteFunc(parent.children, func(c *node) bool { return c == child })
+		parent.rebuildIndices()
+	}
 	n.recomputePriority()
+	return handle
 }
 
 func splitRoutePath(path string) []string {
@@ -62,35 +197,172 @@ func splitRoutePath(path string) []string {
 	return strings.Split(path[1:], "/")
 }
 
-func classifyRouteSegment(segment, fullPath string, last bool) (nodeType, string) {
+// routeSegment is one parsed segment of a route path. A param segment may
+// have a static prefix and suffix around it, as in "report_:id<int>.csv".
+type routeSegment struct {
+	kind       nodeType
+	name       string
+	constraint string
+	prefix     string
+	suffix     string
+	optional   bool
+}
+
+func classifyRouteSegment(segment, fullPath string, last bool) routeSegment {
 	if segment == "" {
-		return static, segment
+		return routeSegment{kind: static, name: segment}
 	}
-	if strings.ContainsAny(segment[1:], ":*") {
-		panic("only one wildcard per path segment is allowed, has: '" + segment + "' in path '" + fullPath + "'")
+	optional := false
+	if segment[len(segment)-1] == '?' && strings.ContainsAny(segment, ":*") {
+		if !last {
+			panic("optional wildcards are only allowed at the end of the path in path '" + fullPath + "'")
+		}
+		optional, segment = true, segment[:len(segment)-1]
 	}
-	switch segment[0] {
-	case ':':
+	if segment[0] == '*' {
 		name := segment[1:]
-		if !validParamName(name) {
-			panic("wildcards must be named with a non-empty simple name in path '" + fullPath + "'")
+		if strings.ContainsAny(name, ":*") {
+			panic("only one wildcard per path segment is allowed, has: '" + segment + "' in path '" + fullPath + "'")
 		}
-		return param, name
-	case '*':
-		name := segment[1:]
 		if !last {
 			panic("catch-all routes are only allowed at the end of the path in path '" + fullPath + "'")
 		}
 		if !validParamName(name) {
 			panic("wildcards must be named with a non-empty simple name in path '" + fullPath + "'")
 		}
-		return catchAll, name
-	default:
-		if strings.ContainsAny(segment, ":*") {
-			panic("wildcards must occupy a full path segment in path '" + fullPath + "'")
+		return routeSegment{kind: catchAll, name: name, optional: optional}
+	}
+
+	colon := strings.IndexByte(segment, ':')
+	if colon < 0 {
+		if strings.IndexByte(segment, '*') >= 0 {
+			panic("catch-all wildcards must occupy a full path segment in path '" + fullPath + "'")
+		}
+		return routeSegment{kind: static, name: segment}
+	}
+	prefix, rest := segment[:colon], segment[colon+1:]
+	if strings.IndexByte(prefix, '*') >= 0 {
+		panic("only one wildcard per path segment is allowed, has: '" + segment + "' in path '" + fullPath + "'")
+	}
+
+	// the name runs until the first byte that can not be part of it
+	end := len(rest)
+	for i, r := range rest {
+		if r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			end = i
+			break
 		}
-		return static, segment
 	}
+	name, rest := rest[:end], rest[end:]
+	if !validParamName(name) {
+		panic("wildcards must be named with a non-empty simple name in path '" + fullPath + "'")
+	}
+
+	constraint := ""
+	if rest != "" && rest[0] == '<' {
+		gt := strings.LastIndexByte(rest, '>')
+		if gt < 2 {
+			panic("constraint must be a non-empty <...> after the parameter name in path '" + fullPath + "'")
+		}
+		constraint, rest = rest[1:gt], rest[gt+1:]
+		compileConstraint(constraint, fullPath)
+	}
+	if strings.ContainsAny(rest, ":*") {
+		panic("only one wildcard per path segment is allowed, has: '" + segment + "' in path '" + fullPath + "'")
+	}
+	if optional && (prefix != "" || rest != "") {
+		panic("optional wildcards can not have a prefix or suffix in path '" + fullPath + "'")
+	}
+	return routeSegment{kind: param, name: name, constraint: constraint, prefix: prefix, suffix: rest, optional: optional}
+}
+
+// paramTypes are the named constraints usable as :name<type>,
+// any other expression is compiled as an anchored regular expression.
+var paramTypes = map[string]func(string) bool{
+	"int":   isIntParam,
+	"uint":  isDigits,
+	"alpha": isAlphaParam,
+	"alnum": isAlnumParam,
+	"uuid":  isUUIDParam,
+}
+
+var constraintCache sync.Map
+
+func compileConstraint(expr, fullPath string) func(string) bool {
+	if check, ok := paramTypes[expr]; ok {
+		return check
+	}
+	if check, ok := constraintCache.Load(expr); ok {
+		return check.(func(string) bool)
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		panic("invalid constraint <" + expr + "> in path '" + fullPath + "': " + err.Error())
+	}
+	check, _ := constraintCache.LoadOrStore(expr, re.MatchString)
+	return check.(func(string) bool)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isIntParam(s string) bool {
+	if s != "" && s[0] == '-' {
+		s = s[1:]
+	}
+	return isDigits(s)
+}
+
+func isAlphaParam(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnumParam(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c|0x20 < 'a' || c|0x20 > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUIDParam(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if c := s[i] | 0x20; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 func validParamName(name string) bool {
@@ -125,28 +397,106 @@ func (n *node) staticChildOrCreate(segment string) *node {
 	return child
 }
 
-func (n *node) paramChild() *node {
+func (n *node) hasParamChild() bool {
 	for _, child := range n.children {
 		if child.nType == param {
+			return true
+		}
+	}
+	return false
+}
+
+// plainParamChild returns the param child without affixes or constraint.
+func (n *node) plainParamChild() *node {
+	for _, child := range n.children {
+		if child.nType == param && child.prefix == "" && child.suffix == "" && child.constraint == "" {
 			return child
 		}
 	}
 	return nil
 }
 
-func (n *node) paramChildOrCreate(name, fullPath string) *node {
-	if child := n.paramChild(); child != nil {
-		if child.path != name {
-			panic(":" + name + " in new path '" + fullPath + "' conflicts with existing wildcard ':" + child.path + "'")
+// paramChild returns the param child registered for seg.
+func (n *node) paramChild(seg routeSegment) *node {
+	for _, child := range n.children {
+		if child.nType == param && child.path == seg.name && child.prefix == seg.prefix &&
+			child.suffix == seg.suffix && child.constraint == seg.constraint {
+			return child
 		}
-		return child
 	}
-	child := &node{nType: param, path: name}
-	n.children = append(n.children, child)
+	return nil
+}
+
+// paramChildOrCreate returns the param child with the same prefix, suffix and
+// constraint as seg. Siblings are kept ordered by paramRank so the most
+// specific param is tried first; equally ranked params that could match the
+// same segment are rejected as ambiguous.
+func (n *node) paramChildOrCreate(seg routeSegment, fullPath string) *node {
+	child := &node{nType: param, path: seg.name, constraint: seg.constraint, prefix: seg.prefix, suffix: seg.suffix}
+	if seg.constraint != "" {
+		child.check = compileConstraint(seg.constraint, fullPath)
+	}
+	for _, existing := range n.children {
+		if existing.nType != param {
+			continue
+		}
+		if existing.prefix == child.prefix && existing.suffix == child.suffix && existing.constraint == child.constraint {
+			if existing.path != child.path {
+				panic(":" + seg.name + " in new path '" + fullPath + "' conflicts with existing wildcard ':" + existing.path + "'")
+			}
+			return existing
+		}
+		if existing.paramRank() == child.paramRank() && existing.constraint == child.constraint &&
+			(strings.HasPrefix(existing.prefix, child.prefix) || strings.HasPrefix(child.prefix, existing.prefix)) &&
+			(strings.HasSuffix(existing.suffix, child.suffix) || strings.HasSuffix(child.suffix, existing.suffix)) {
+			panic("'" + seg.prefix + ":" + seg.name + seg.suffix + "' in new path '" + fullPath + "' is ambiguous with existing wildcard '" +
+				existing.prefix + ":" + existing.path + existing.suffix + "'")
+		}
+	}
+	pos := len(n.children)
+	for i, existing := range n.children {
+		if existing.nType == param && existing.paramRank() < child.paramRank() {
+			pos = i
+			break
+		}
+	}
+	n.children = append(n.children, nil)
+	copy(n.children[pos+1:], n.children[pos:])
+	n.children[pos] = child
 	n.rebuildIndices()
 	return child
 }
 
+// paramRank orders param siblings, higher ranks are matched first:
+// longer static prefix+suffix wins, then a constraint wins over none.
+func (n *node) paramRank() int {
+	rank := 2 * (len(n.prefix) + len(n.suffix))
+	if n.check != nil {
+		rank++
+	}
+	return rank
+}
+
+// bind returns the param value of segment if the param node accepts it.
+// With fold the static prefix and suffix are compared case-insensitively.
+func (n *node) bind(segment string, fold bool) (string, bool) {
+	if len(segment) <= len(n.prefix)+len(n.suffix) {
+		return "", false
+	}
+	head, value, tail := segment[:len(n.prefix)], segment[len(n.prefix):len(segment)-len(n.suffix)], segment[len(segment)-len(n.suffix):]
+	if fold {
+		if !strings.EqualFold(head, n.prefix) || !strings.EqualFold(tail, n.suffix) {
+			return "", false
+		}
+	} else if head != n.prefix || tail != n.suffix {
+		return "", false
+	}
+	if n.check != nil && !n.check(value) {
+		return "", false
+	}
+	return value, true
+}
+
 func (n *node) catchAllChild() *node {
 	for _, child := range n.children {
 		if child.nType == catchAll {
@@ -169,6 +519,16 @@ func (n *node) catchAllChildOrCreate(name, fullPath string) *node {
 	return child
 }
 
+// optionalChild returns the optional wildcard child that answers for n itself.
+func (n *node) optionalChild() *node {
+	for _, child := range n.children {
+		if child.optional {
+			return child
+		}
+	}
+	return nil
+}
+
 func (n *node) rebuildIndices() {
 	var b strings.Builder
 	for _, child := range n.children {
@@ -178,7 +538,7 @@ func (n *node) rebuildIndices() {
 		b.WriteByte(child.path[0])
 	}
 	n.indices = b.String()
-	n.wildChild = n.paramChild() != nil || n.catchAllChild() != nil
+	n.wildChild = n.hasParamChild() || n.catchAllChild() != nil
 }
 
 func (n *node) recomputePriority() uint32 {
@@ -193,124 +553,218 @@ func (n *node) recomputePriority() uint32 {
 	return priority
 }
 
-func (n *node) getValue(path string, params func() *Params) (handle interface{}, ps *Params, tsr bool) {
-	segments := splitRoutePath(path)
-	if match, ok := n.match(segments, 0); ok {
-		if params != nil && len(match.params) > 0 {
-			ps = params()
-			*ps = append(*ps, match.params...)
-		}
-		return match.handle, ps, false
+// getValue returns the handle registered for path. The params of the match are
+// appended to ps, which may be nil when they are not needed; nothing is left in
+// ps when path matches no route. tsr reports that path would match with a
+// trailing slash added or removed. Matching walks path in place and does not
+// allocate.
+func (n *node) getValue(path string, ps *Params) (handle interface{}, tsr bool) {
+	m := matcher{path: path, end: len(path), ps: ps}
+	if handle = n.match(m.first(), &m); handle != nil {
+		return handle, false
 	}
 	if path != "/" {
-		alt := path + "/"
-		if strings.HasSuffix(path, "/") {
-			alt = strings.TrimSuffix(path, "/")
-			if alt == "" {
-				alt = "/"
+		alt := m.toggled()
+		if n.match(alt.first(), &alt) != nil {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// matcher walks a path in place. end is len(path), or one more when path is
+// matched as if it had a trailing slash. Params are appended to ps, if any,
+// and truncated again when the walk backtracks.
+type matcher struct {
+	path string
+	end  int
+	fold bool
+	ps   *Params
+}
+
+// toggled returns a matcher for the path of m with its trailing slash
+// removed or added. It does not collect params.
+func (m *matcher) toggled() matcher {
+	alt := matcher{path: m.path, end: len(m.path) + 1, fold: m.fold}
+	if strings.HasSuffix(m.path, "/") {
+		alt.path = m.path[:len(m.path)-1]
+		alt.end = len(alt.path)
+	}
+	return alt
+}
+
+// first returns the index of the first segment, which is past end when the
+// path has no segments.
+func (m *matcher) first() int {
+	if m.path == "/" || m.path == "" {
+		return m.end + 1
+	}
+	return 1
+}
+
+// segment returns the segment at i and the index of the slash ending it.
+func (m *matcher) segment(i int) (string, int) {
+	if i >= len(m.path) {
+		return "", i
+	}
+	if j := strings.IndexByte(m.path[i:], '/'); j >= 0 {
+		return m.path[i : i+j], i + j
+	}
+	return m.path[i:], len(m.path)
+}
+
+// rest returns the catch-all value for the segments from i on.
+func (m *matcher) rest(i int) string {
+	if m.end > len(m.path) {
+		return m.path[i-1:] + "/"
+	}
+	return m.path[i-1:]
+}
+
+// static matches the segments of a static node path, which spans several
+// segments in a compressed tree, from i on. It returns the index of the
+// segment after them.
+func (m *matcher) static(i int, path string) (int, bool) {
+	for {
+		if i > m.end {
+			return 0, false
+		}
+		segment, end := m.segment(i)
+		want, rest, more := strings.Cut(path, "/")
+		if m.fold {
+			if !strings.EqualFold(segment, want) {
+				return 0, false
 			}
+		} else if segment != want {
+			return 0, false
 		}
-		if _, ok := n.match(splitRoutePath(alt), 0); ok {
-			return nil, nil, true
+		i = end + 1
+		if !more {
+			return i, true
 		}
+		path = rest
 	}
-	return nil, nil, false
 }
 
-type routeMatch struct {
-	handle interface{}
-	params Params
+func (m *matcher) bind(key, value string) int {
+	if m.ps == nil {
+		return 0
+	}
+	mark := len(*m.ps)
+	*m.ps = append(*m.ps, Param{Key: key, Value: value})
+	return mark
 }
 
-func (n *node) match(segments []string, index int) (routeMatch, bool) {
-	if index == len(segments) {
-		if n.handle != nil {
-			return routeMatch{handle: n.handle}, true
-		}
-		return routeMatch{}, false
+func (m *matcher) unbind(mark int) {
+	if m.ps != nil {
+		*m.ps = (*m.ps)[:mark]
 	}
+}
 
-	segment := segments[index]
-	if child := n.staticChild(segment); child != nil {
-		if match, ok := child.match(segments, index+1); ok {
-			return match, true
+// match returns the handle for the segments from i on, trying static children
+// before params before the catch-all.
+func (n *node) match(i int, m *matcher) interface{} {
+	if i > m.end {
+		if handleServes(n.handle) {
+			return n.handle
 		}
+		if child := n.optionalChild(); child != nil {
+			m.bind(child.path, "")
+			return child.handle
+		}
+		return n.handle
 	}
 
-	if segment != "" {
-		if child := n.paramChild(); child != nil {
-			if match, ok := child.match(segments, index+1); ok {
-				match.params = append(Params{{Key: child.path, Value: segment}}, match.params...)
-				return match, true
+	for _, child := range n.children {
+		if child.nType != static {
+			continue
+		}
+		if next, ok := m.static(i, child.path); ok {
+			if handle := child.match(next, m); handle != nil {
+				return handle
 			}
 		}
 	}
 
+	segment, end := m.segment(i)
+	for _, child := range n.children {
+		if child.nType != param {
+			continue
+		}
+		value, ok := child.bind(segment, false)
+		if !ok {
+			continue
+		}
+		mark := m.bind(child.path, value)
+		if handle := child.match(end+1, m); handle != nil {
+			return handle
+		}
+		m.unbind(mark)
+	}
+
 	if child := n.catchAllChild(); child != nil && child.handle != nil {
-		value := "/" + strings.Join(segments[index:], "/")
-		return routeMatch{
-			handle: child.handle,
-			params: Params{{Key: child.path, Value: value}},
-		}, true
+		if m.ps != nil {
+			m.bind(child.path, m.rest(i))
+		}
+		return child.handle
 	}
 
-	return routeMatch{}, false
+	return nil
 }
 
 func (n *node) findCaseInsensitivePath(path string, fixTrailingSlash bool) (fixedPath string, found bool) {
-	segments := splitRoutePath(path)
-	if fixed, ok := n.matchCaseInsensitive(segments, 0); ok {
-		if fixed == "" {
-			fixed = "/"
-		}
-		return fixed, true
-	}
-	if fixTrailingSlash && path != "/" {
-		alt := path + "/"
-		if strings.HasSuffix(path, "/") {
-			alt = strings.TrimSuffix(path, "/")
-			if alt == "" {
-				alt = "/"
-			}
-		}
-		if fixed, ok := n.matchCaseInsensitive(splitRoutePath(alt), 0); ok {
-			if fixed == "" {
-				fixed = "/"
-			}
-			return fixed, true
-		}
+	m := matcher{path: path, end: len(path), fold: true}
+	fixed, ok := n.matchCaseInsensitive(m.first(), &m)
+	if !ok && fixTrailingSlash && path != "/" {
+		alt := m.toggled()
+		fixed, ok = n.matchCaseInsensitive(alt.first(), &alt)
 	}
-	return "", false
+	if !ok {
+		return "", false
+	}
+	if fixed == "" {
+		fixed = "/"
+	}
+	return fixed, true
 }
 
-func (n *node) matchCaseInsensitive(segments []string, index int) (string, bool) {
-	if index == len(segments) {
-		if n.handle != nil {
+func (n *node) matchCaseInsensitive(i int, m *matcher) (string, bool) {
+	if i > m.end {
+		if n.handle != nil || n.optionalChild() != nil {
 			return "", true
 		}
 		return "", false
 	}
 
-	segment := segments[index]
 	for _, child := range n.children {
-		if child.nType != static || !strings.EqualFold(child.path, segment) {
+		if child.nType != static {
+			continue
+		}
+		next, ok := m.static(i, child.path)
+		if !ok {
 			continue
 		}
-		if suffix, ok := child.matchCaseInsensitive(segments, index+1); ok {
+		if suffix, ok := child.matchCaseInsensitive(next, m); ok {
 			return joinFixedPath(child.path, suffix), true
 		}
 	}
 
-	if segment != "" {
-		if child := n.paramChild(); child != nil {
-			if suffix, ok := child.matchCaseInsensitive(segments, index+1); ok {
-				return joinFixedPath(segment, suffix), true
-			}
+	segment, end := m.segment(i)
+	for _, child := range n.children {
+		if child.nType != param {
+			continue
+		}
+		value, ok := child.bind(segment, true)
+		if !ok {
+			continue
+		}
+		if suffix, ok := child.matchCaseInsensitive(end+1, m); ok {
+			return joinFixedPath(child.prefix+value+child.suffix, suffix), true
 		}
 	}
 
 	if child := n.catchAllChild(); child != nil && child.handle != nil {
-		return "/" + strings.Join(segments[index:], "/"), true
+		return m.rest(i), true
 	}
 
 	return "", false
@@ -39,9 +39,18 @@ type testRequests []struct {
 	ps         Params
 }
 
+// checkRequests matches requests against tree and against its compressed copy.
 func checkRequests(t *testing.T, tree *node, requests testRequests) {
+	compressed := tree.clone(func(h interface{}) interface{} { return h })
+	compressed.compress()
+	checkTreeRequests(t, tree, requests)
+	checkTreeRequests(t, compressed, requests)
+}
+
+func checkTreeRequests(t *testing.T, tree *node, requests testRequests) {
 	for _, request := range requests {
-		handler, ps, _ := tree.getValue(request.path, getParams)
+		ps := getParams()
+		handler, _ := tree.getValue(request.path, ps)
 		if handler == nil {
 			if !request.nilHandler {
 				t.Errorf("handle mismatch for route '%s': Expected non-nil handle", request.path)
@@ -56,7 +65,7 @@ func checkRequests(t *testing.T, tree *node, requests testRequests) {
 			}
 		}
 
-		if ps != nil {
+		if handler != nil && (len(*ps) > 0 || len(request.ps) > 0) {
 			if !reflect.DeepEqual(*ps, request.ps) {
 				t.Errorf("Params mismatch for route '%s'", request.path)
 			}
@@ -236,9 +245,9 @@ func TestTreeWildcardConflict(t *testing.T) {
 		{"/search/:query", false},
 		{"/search/invalid", false},
 		{"/search/:term", true},
-		{"/user_:name", true},
+		{"/user_:name", false},
 		{"/user_x", false},
-		{"/id:id", true},
+		{"/id:id", false},
 		{"/id/:id", false},
 	}
 	testRoutes(t, routes)
@@ -251,9 +260,9 @@ func TestTreeChildConflict(t *testing.T) {
 		{"/src/AUTHORS", false},
 		{"/src/*filepath", false},
 		{"/user_x", false},
-		{"/user_:name", true},
+		{"/user_:name", false},
 		{"/id/:id", false},
-		{"/id:id", true},
+		{"/id:id", false},
 		{"/:id", false},
 		{"/*filepath", false},
 	}
@@ -421,7 +430,7 @@ func TestTreeTrailingSlashRedirect(t *testing.T) {
 		"/doc/",
 	}
 	for _, route := range tsrRoutes {
-		handler, _, tsr := tree.getValue(route, getParams)
+		handler, tsr := tree.getValue(route, getParams())
 		if handler != nil {
 			t.Fatalf("non-nil handler for TSR route '%s", route)
 		} else if !tsr {
@@ -438,7 +447,7 @@ func TestTreeTrailingSlashRedirect(t *testing.T) {
 		"/api/world/abc",
 	}
 	for _, route := range noTsrRoutes {
-		handler, _, tsr := tree.getValue(route, getParams)
+		handler, tsr := tree.getValue(route, getParams())
 		if handler != nil {
 			t.Fatalf("non-nil handler for No-TSR route '%s", route)
 		} else if tsr {
@@ -457,7 +466,7 @@ func TestTreeRootTrailingSlashRedirect(t *testing.T) {
 		t.Fatalf("panic inserting test route: %v", recv)
 	}
 
-	handler, _, tsr := tree.getValue("/", getParams)
+	handler, tsr := tree.getValue("/", getParams())
 	if handler != nil {
 		t.Fatalf("non-nil handler")
 	} else if tsr {
@@ -628,7 +637,7 @@ func TestTreeInvalidNodeType(t *testing.T) {
 	// set invalid node type
 	tree.children[0].nType = 42
 
-	if handler, _, _ := tree.getValue("/test", getParams); handler != nil {
+	if handler, _ := tree.getValue("/test", getParams()); handler != nil {
 		t.Fatalf("expected invalid child type to be ignored")
 	}
 }
@@ -643,3 +652,266 @@ func TestTreeWildcardConflictEx(t *testing.T) {
 		t.Fatalf("expected equivalent param route to panic")
 	}
 }
+
+func TestTreeParamConstraints(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/users/:id<int>",
+		"/users/:name",
+		"/users/me",
+		"/posts/:slug<[a-z0-9-]+>",
+		"/posts/:slug<[a-z0-9-]+>/comments",
+		"/objects/:uuid<uuid>",
+		"/objects/:key<alpha>",
+		"/files/:id<int>/*filepath",
+	}
+	for _, route := range routes {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv != nil {
+			t.Fatalf("panic inserting route '%s': %v", route, recv)
+		}
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/users/42", false, "/users/:id<int>", Params{Param{"id", "42"}}},
+		{"/users/-7", false, "/users/:id<int>", Params{Param{"id", "-7"}}},
+		{"/users/abc", false, "/users/:name", Params{Param{"name", "abc"}}},
+		{"/users/me", false, "/users/me", nil},
+		{"/posts/hello-world-2", false, "/posts/:slug<[a-z0-9-]+>", Params{Param{"slug", "hello-world-2"}}},
+		{"/posts/Hello", true, "", nil},
+		{"/posts/go-1/comments", false, "/posts/:slug<[a-z0-9-]+>/comments", Params{Param{"slug", "go-1"}}},
+		{"/objects/123e4567-e89b-12d3-a456-426614174000", false, "/objects/:uuid<uuid>", Params{Param{"uuid", "123e4567-e89b-12d3-a456-426614174000"}}},
+		{"/objects/abc", false, "/objects/:key<alpha>", Params{Param{"key", "abc"}}},
+		{"/objects/123", true, "", nil},
+		{"/files/7/a/b.txt", false, "/files/:id<int>/*filepath", Params{Param{"id", "7"}, Param{"filepath", "/a/b.txt"}}},
+		{"/files/x/a/b.txt", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+}
+
+func TestTreeParamConstraintConflict(t *testing.T) {
+	routes := []testRoute{
+		{"/users/:id<int>", false},
+		{"/users/:id", false},
+		{"/users/:uid<int>", true},
+		{"/users/:id<uuid>", false},
+		{"/users/:id<int>/posts", false},
+		{"/bad/:id<", true},
+		{"/bad/:id<>", true},
+		{"/bad/:id<[a-z>", true},
+		{"/bad/:id<int>x", false},
+		{"/bad/*path<int>", true},
+	}
+	testRoutes(t, routes)
+}
+
+func TestTreeParamAffixes(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/report/:id.csv",
+		"/report/:id.json",
+		"/report/:id<int>.xml",
+		"/report/:id",
+		"/report/latest.csv",
+		"/user_:name",
+		"/user_:name/avatar",
+		"/img/thumb_:id.png",
+		"/img/:name",
+	}
+	for _, route := range routes {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv != nil {
+			t.Fatalf("panic inserting route '%s': %v", route, recv)
+		}
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/report/7.csv", false, "/report/:id.csv", Params{Param{"id", "7"}}},
+		{"/report/7.json", false, "/report/:id.json", Params{Param{"id", "7"}}},
+		{"/report/7.xml", false, "/report/:id<int>.xml", Params{Param{"id", "7"}}},
+		{"/report/x.xml", false, "/report/:id", Params{Param{"id", "x.xml"}}},
+		{"/report/latest.csv", false, "/report/latest.csv", nil},
+		{"/report/.csv", false, "/report/:id", Params{Param{"id", ".csv"}}},
+		{"/report/7", false, "/report/:id", Params{Param{"id", "7"}}},
+		{"/user_gordon", false, "/user_:name", Params{Param{"name", "gordon"}}},
+		{"/user_gordon/avatar", false, "/user_:name/avatar", Params{Param{"name", "gordon"}}},
+		{"/user_", true, "", nil},
+		{"/img/thumb_1.png", false, "/img/thumb_:id.png", Params{Param{"id", "1"}}},
+		{"/img/1.png", false, "/img/:name", Params{Param{"name", "1.png"}}},
+	})
+
+	checkPriorities(t, tree)
+
+	if out, found := tree.findCaseInsensitivePath("/REPORT/Ab.CSV", false); !found || out != "/report/Ab.csv" {
+		t.Errorf("Wrong case-insensitive result for affix param: %s, %t", out, found)
+	}
+}
+
+func TestTreeParamAffixConflict(t *testing.T) {
+	routes := []testRoute{
+		{"/r/:id.json", false},
+		{"/r/:name.json", true},
+		{"/r/:id.csv", false},
+		{"/r/x_:id", false},
+		{"/r/:id.y", true},
+		{"/r/abc_:id", true},
+		{"/r/abcdef_:id", false},
+		{"/r/:id<int>.json", false},
+		{"/r/:a.:b", true},
+		{"/r/:id*x", true},
+		{"/r/x*:id", true},
+		{"/r/x*id", true},
+	}
+	testRoutes(t, routes)
+}
+
+func TestTreeOptionalWildcards(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/list/:page?",
+		"/users/:id<int>?",
+		"/docs/*path?",
+		"/docs2/",
+		"/docs2/*path?",
+	}
+	for _, route := range routes {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv != nil {
+			t.Fatalf("panic inserting route '%s': %v", route, recv)
+		}
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/list", false, "/list/:page?", Params{Param{"page", ""}}},
+		{"/list/2", false, "/list/:page?", Params{Param{"page", "2"}}},
+		{"/users", false, "/users/:id<int>?", Params{Param{"id", ""}}},
+		{"/users/5", false, "/users/:id<int>?", Params{Param{"id", "5"}}},
+		{"/users/x", true, "", nil},
+		{"/docs", false, "/docs/*path?", Params{Param{"path", ""}}},
+		{"/docs/", false, "/docs/*path?", Params{Param{"path", "/"}}},
+		{"/docs/a/b", false, "/docs/*path?", Params{Param{"path", "/a/b"}}},
+		{"/docs2", false, "/docs2/*path?", Params{Param{"path", ""}}},
+		{"/docs2/", false, "/docs2/", nil},
+	})
+
+	// trailing slash redirect stays consistent
+	if handler, tsr := tree.getValue("/list/", getParams()); handler != nil || !tsr {
+		t.Errorf("expected TSR recommendation for '/list/'")
+	}
+	if out, found := tree.findCaseInsensitivePath("/LIST", false); !found || out != "/list" {
+		t.Errorf("Wrong case-insensitive result for optional wildcard: %s, %t", out, found)
+	}
+
+	checkPriorities(t, tree)
+}
+
+func TestTreeOptionalWildcardConflict(t *testing.T) {
+	routes := []testRoute{
+		{"/list", false},
+		{"/list/:page?", true},
+		{"/docs/*path?", false},
+		{"/docs", true},
+		{"/users/:id<int>?", false},
+		{"/users/:name?", true},
+		{"/posts/:id?/comments", true},
+		{"/posts/:id.json?", true},
+		{"/posts/x_:id?", true},
+	}
+	tree := &node{}
+	for _, route := range routes {
+		recv := catchPanic(func() {
+			tree.addRoute(route.path, fakeHandler(route.path))
+		})
+		if route.conflict && recv == nil {
+			t.Errorf("no panic for conflicting route '%s'", route.path)
+		} else if !route.conflict && recv != nil {
+			t.Errorf("unexpected panic for route '%s': %v", route.path, recv)
+		}
+	}
+}
+
+func TestTreeRemoveRoute(t *testing.T) {
+	tree := &node{}
+	routes := [...]string{
+		"/hi",
+		"/hi/there",
+		"/users/:id<int>",
+		"/users/:name",
+		"/report/:id.csv",
+		"/files/*path",
+		"/list/:page?",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	for _, route := range []string{"/hi/there", "/users/:id<int>", "/report/:id.csv", "/files/*path", "/list/:page?"} {
+		if handle := tree.removeRoute(route); handle == nil {
+			t.Errorf("expected handle for removed route %s", route)
+		}
+	}
+	if handle := tree.removeRoute("/hi/there"); handle != nil {
+		t.Error("expected nil handle for a route removed twice")
+	}
+	if handle := tree.removeRoute("/users/:other"); handle != nil {
+		t.Error("expected nil handle for a route that was never added")
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/hi", false, "/hi", nil},
+		{"/hi/there", true, "", nil},
+		{"/users/1", false, "/users/:name", Params{Param{"name", "1"}}},
+		{"/report/1.csv", true, "", nil},
+		{"/files/a", true, "", nil},
+		{"/list", true, "", nil},
+	})
+	checkPriorities(t, tree)
+
+	// pruned nodes leave no empty branches behind
+	for _, child := range tree.children {
+		if child.path == "report" || child.path == "files" || child.path == "list" {
+			t.Errorf("expected node %s to be pruned", child.path)
+		}
+	}
+	if tree.indices != "hu" {
+		t.Errorf("expected indices %q, got %q", "hu", tree.indices)
+	}
+
+	// removed paths can be registered again
+	tree.addRoute("/list/:page", fakeHandler("/list/:page"))
+	checkRequests(t, tree, testRequests{
+		{"/list/2", false, "/list/:page", Params{Param{"page", "2"}}},
+	})
+}
+
+func TestTreeMatchAllocs(t *testing.T) {
+	e := benchTree()
+	tree := e.routing().table.tree
+	for _, path := range []string{
+		"/api/v1/users",
+		"/api/v1/users/me",
+		"/api/v1/users/42/posts/7",
+		"/static/css/site.css",
+		"/api/v1/users/me/avatar", // not found after backtracking
+		"/api/v1/users/",          // trailing slash recommendation
+	} {
+		allocs := testing.AllocsPerRun(100, func() {
+			ps := e.getParams()
+			tree.getValue(path, ps)
+			e.putParams(ps)
+		})
+		if allocs != 0 {
+			t.Errorf("matching %s: %v allocs, want 0", path, allocs)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package cart
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Name registers r under name so its path can be rebuilt with Engine.URL.
+func (r *Router) Name(name string) *Router {
+	e := r.Engine
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.frozen {
+		panic("routes cannot be changed after Engine.Freeze")
+	}
+	// the name belongs to the registered router of the path, r may be a
+	// group view or a router that has no handler yet
+	named := r
+	if tr := r.table.routers[r.Path]; tr != nil {
+		named = tr
+	}
+	if exist, ok := e.names[name]; ok && exist.Path != r.Path {
+		panic("route name '" + name + "' is already registered for path '" + exist.Path + "'")
+	}
+	named.name = name
+	e.names[name] = named
+	return r
+}
+
+// URL rebuilds the path of the route registered with Router.Name.
+// Params fill the :param and *catchall segments in order.
+func (e *Engine) URL(name string, params ...interface{}) (string, error) {
+	e.mu.RLock()
+	router := e.names[name]
+	e.mu.RUnlock()
+	if router == nil {
+		return "", fmt.Errorf("route %s not found", name)
+	}
+	return buildPath(router.Path, params)
+}
+
+// URL is a shortcut for c.Router.Engine.URL(name, params...)
+func (c *Context) URL(name string, params ...interface{}) (string, error) {
+	if c.Router == nil {
+		return "", fmt.Errorf("route %s not found", name)
+	}
+	return c.Router.Engine.URL(name, params...)
+}
+
+func buildPath(path string, params []interface{}) (string, error) {
+	segments := splitRoutePath(path)
+	parts := make([]string, 0, len(segments))
+	used := 0
+	for i, segment := range segments {
+		seg := classifyRouteSegment(segment, path, i == len(segments)-1)
+		kind, name := seg.kind, seg.name
+		if kind == static {
+			parts = append(parts, segment)
+			continue
+		}
+		if seg.optional && (used == len(params) || fmt.Sprint(params[used]) == "") {
+			if used < len(params) {
+				used++
+			}
+			continue
+		}
+		if used == len(params) {
+			return "", fmt.Errorf("parameter %s is missing for path %s", name, path)
+		}
+		value := fmt.Sprint(params[used])
+		used++
+		switch kind {
+		case param:
+			if value == "" || strings.Contains(value, "/") ||
+				(seg.constraint != "" && !compileConstraint(seg.constraint, path)(value)) {
+				return "", fmt.Errorf("parameter %s has invalid value %q for path %s", name, value, path)
+			}
+			parts = append(parts, seg.prefix+url.PathEscape(value)+seg.suffix)
+		case catchAll:
+			pieces := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j, piece := range pieces {
+				pieces[j] = url.PathEscape(piece)
+			}
+			parts = append(parts, strings.Join(pieces, "/"))
+		}
+	}
+	if used != len(params) {
+		return "", fmt.Errorf("too many parameters for path %s", path)
+	}
+	return "/" + strings.Join(parts, "/"), nil
+}
@@ -0,0 +1,167 @@
+package cart
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEngineURL(t *testing.T) {
+	app := New()
+	noop := func(c *Context) error { return nil }
+	app.Route("/").GET(noop).Name("home")
+	app.Route("/users/:id").GET(noop).Name("user.show")
+	app.Route("/users/:id/posts/:post").GET(noop).Name("user.post")
+	app.Route("/static/*filepath").GET(noop).Name("static")
+	app.Route("/doc/").GET(noop).Name("doc")
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		out    string
+	}{
+		{"home", nil, "/"},
+		{"user.show", []interface{}{42}, "/users/42"},
+		{"user.show", []interface{}{"a b"}, "/users/a%20b"},
+		{"user.post", []interface{}{"gordon", 7}, "/users/gordon/posts/7"},
+		{"static", []interface{}{"/css/app.css"}, "/static/css/app.css"},
+		{"static", []interface{}{"js/a b.js"}, "/static/js/a%20b.js"},
+		{"doc", nil, "/doc/"},
+	}
+	for _, tt := range tests {
+		out, err := app.URL(tt.name, tt.params...)
+		if err != nil {
+			t.Errorf("URL(%s) unexpected error: %v", tt.name, err)
+		} else if out != tt.out {
+			t.Errorf("URL(%s) = %s, want %s", tt.name, out, tt.out)
+		}
+	}
+
+	errors := []struct {
+		name   string
+		params []interface{}
+	}{
+		{"unknown", nil},
+		{"user.show", nil},
+		{"user.show", []interface{}{""}},
+		{"user.show", []interface{}{"a/b"}},
+		{"user.show", []interface{}{1, 2}},
+		{"user.post", []interface{}{"gordon"}},
+	}
+	for _, tt := range errors {
+		if out, err := app.URL(tt.name, tt.params...); err == nil {
+			t.Errorf("URL(%s, %v) expected error, got %s", tt.name, tt.params, out)
+		}
+	}
+}
+
+func TestRouterNameConflict(t *testing.T) {
+	app := New()
+	app.Route("/a").Name("dup")
+	recv := catchPanic(func() {
+		app.Route("/b").Name("dup")
+	})
+	if recv == nil {
+		t.Error("expected panic for duplicate route name")
+	}
+}
+
+func TestContextURLAndTemplate(t *testing.T) {
+	app := New()
+	app.SetFuncMap(template.FuncMap{"upper": strings.ToUpper})
+	app.SetHTMLTemplate(template.Must(template.New("link").Funcs(app.FuncMap).Parse(`<a href="{{url "user.show" .ID}}">{{upper .Name}}</a>`)))
+	app.Route("/users/:id").GET(func(c *Context) error {
+		c.HTML(200, "link", H{"ID": 5, "Name": "bob"})
+		return nil
+	}).Name("user.show")
+	app.Route("/self").GET(func(c *Context) error {
+		u, err := c.URL("user.show", 9)
+		if err != nil {
+			return err
+		}
+		c.String(200, u)
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/users/5", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `<a href="/users/5">BOB</a>`) {
+		t.Errorf("unexpected template output %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/self", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Body.String() != "/users/9" {
+		t.Errorf("expected /users/9, got %s", w.Body.String())
+	}
+}
+
+func TestEngineURLConstraint(t *testing.T) {
+	app := New()
+	app.Route("/users/:id<int>").GET(func(c *Context) error { return nil }).Name("user")
+
+	if out, err := app.URL("user", 12); err != nil || out != "/users/12" {
+		t.Errorf("URL(user, 12) = %s, %v", out, err)
+	}
+	if out, err := app.URL("user", "abc"); err == nil {
+		t.Errorf("expected constraint error, got %s", out)
+	}
+
+	app.Route("/report/:id<int>.csv").GET(func(c *Context) error { return nil }).Name("report.csv")
+	if out, err := app.URL("report.csv", 3); err != nil || out != "/report/3.csv" {
+		t.Errorf("URL(report.csv, 3) = %s, %v", out, err)
+	}
+}
+
+func TestEngineURLOptional(t *testing.T) {
+	app := New()
+	noop := func(c *Context) error { return nil }
+	app.Route("/list/:page?").GET(noop).Name("list")
+	app.Route("/docs/*path?").GET(noop).Name("docs")
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		out    string
+	}{
+		{"list", nil, "/list"},
+		{"list", []interface{}{""}, "/list"},
+		{"list", []interface{}{3}, "/list/3"},
+		{"docs", nil, "/docs"},
+		{"docs", []interface{}{"/a/b"}, "/docs/a/b"},
+	}
+	for _, tt := range tests {
+		if out, err := app.URL(tt.name, tt.params...); err != nil || out != tt.out {
+			t.Errorf("URL(%s, %v) = %s, %v; want %s", tt.name, tt.params, out, err, tt.out)
+		}
+	}
+}
+
+func TestRouterNameBeforeHandler(t *testing.T) {
+	app := New()
+	noop := func(c *Context) error { return nil }
+	app.Route("/u/:id", func(r *Router) {
+		r.Name("u")
+		r.GET(noop)
+	})
+	named := app.Route("/posts/:post").Name("post")
+	app.Route("/posts/:post").GET(noop)
+	named.PUT(noop)
+
+	names := map[string]string{}
+	for _, route := range app.Routes() {
+		names[route.Path] = route.Name
+	}
+	if names["/u/:id"] != "u" || names["/posts/:post"] != "post" {
+		t.Errorf("expected names to reach the registered routes, got %v", names)
+	}
+	if out, err := app.URL("u", 7); err != nil || out != "/u/7" {
+		t.Errorf("URL(u) = %s, %v", out, err)
+	}
+	if out, err := app.URL("post", 3); err != nil || out != "/posts/3" {
+		t.Errorf("URL(post) = %s, %v", out, err)
+	}
+}
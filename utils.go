@@ -4,6 +4,8 @@ import (
 	"encoding/xml"
 	"os"
 	"path"
+	"reflect"
+	"runtime"
 	"strings"
 )
 
@@ -86,6 +88,58 @@ func joinPaths(absolutePath, relativePath string) string {
 	return finalPath
 }
 
+// cleanPath returns the canonical form of p: rooted, without repeated slashes
+// or . and .. elements, keeping a trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+// validMethod reports whether m is a method token as defined by RFC 9110.
+func validMethod(m string) bool {
+	if m == "" {
+		return false
+	}
+	for i := 0; i < len(m); i++ {
+		c := m[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			continue
+		}
+		if !strings.ContainsRune("!#$%&'*+-.^_`|~", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// underPath reports whether p is path or below it, with or without
+// a trailing slash.
+func underPath(p, path string) bool {
+	prefix := strings.TrimSuffix(path, "/")
+	return p == prefix || p == prefix+"/" || strings.HasPrefix(p, prefix+"/")
+}
+
+// toggleTrailingSlash adds a trailing slash to p or removes it.
+func toggleTrailingSlash(p string) string {
+	if len(p) > 1 && p[len(p)-1] == '/' {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
 /*
 transfer Handler to HandlerCompose func
 */
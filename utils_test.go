@@ -55,3 +55,23 @@ func TestHMarshalXML(t *testing.T) {
 		t.Errorf("Expected XML output, got empty")
 	}
 }
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"abc", "/abc"},
+		{"//a//b", "/a/b"},
+		{"/a/./b/", "/a/b/"},
+		{"/a/../b", "/b"},
+		{"/../a", "/a"},
+		{"/a/b/..", "/a"},
+	}
+	for _, tt := range tests {
+		if out := cleanPath(tt.in); out != tt.out {
+			t.Errorf("cleanPath(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}